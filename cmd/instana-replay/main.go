@@ -0,0 +1,126 @@
+// Command instana-replay sends bundles from the exporter's dead-letter files to an Instana acceptor.
+//
+// Usage:
+//
+//	instana-replay -endpoint https://serverless-red-saas.instana.io [-agent-key key] [-destination name] path...
+//
+// Paths are dead-letter files or directories containing them. The agent key defaults to the INSTANA_AGENT_KEY
+// environment variable.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/deadletter"
+	"github.com/ibm-observability/instanaexporter/internal/filesink"
+)
+
+func main() {
+	endpoint := flag.String("endpoint", "", "Instana backend endpoint the bundles are sent to")
+	agentKey := flag.String("agent-key", os.Getenv("INSTANA_AGENT_KEY"), "Instana agent key, defaults to $INSTANA_AGENT_KEY")
+	destination := flag.String("destination", "", "only replay bundles rejected by this destination")
+	timeout := flag.Duration("timeout", 30*time.Second, "timeout of each request")
+	flag.Parse()
+
+	if *endpoint == "" || *agentKey == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	files, err := collectFiles(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	url := strings.TrimSuffix(*endpoint, "/") + "/bundle"
+
+	sent, failed, skipped := 0, 0, 0
+	for _, file := range files {
+		records, err := deadletter.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		for i, record := range records {
+			if len(record.Bundle) == 0 || (*destination != "" && record.Destination != *destination) {
+				skipped++
+				continue
+			}
+
+			if err := replay(client, url, *agentKey, record); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: record %d: %v\n", file, i+1, err)
+				failed++
+				continue
+			}
+			sent++
+		}
+	}
+
+	fmt.Printf("replayed %d bundles, %d failed, %d skipped\n", sent, failed, skipped)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// collectFiles expands directories to the dead-letter files they contain
+func collectFiles(paths []string) ([]string, error) {
+	files := make([]string, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		dirFiles, err := filesink.Files(path, deadletter.FilePrefix)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dirFiles...)
+	}
+
+	return files, nil
+}
+
+func replay(client *http.Client, url string, agentKey string, record deadletter.Record) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(record.Bundle))
+	if err != nil {
+		return err
+	}
+
+	for name, value := range record.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(config.HeaderKey, agentKey)
+	req.Header.Set(config.HeaderTime, strconv.FormatInt(time.Now().UnixMilli(), 10))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("the Instana acceptor responded with HTTP status %d: %s", resp.StatusCode, body)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+// BatchingConfig defines the aggregation of repetitive fast sibling spans into Instana batch spans
+type BatchingConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+
+	// MaxDuration is the duration spans must stay below to be aggregated
+	MaxDuration time.Duration `mapstructure:"max_duration"`
+}
+
+// Validate checks if the batching configuration is valid
+func (cfg *BatchingConfig) Validate() error {
+	if cfg.Enabled && cfg.MaxDuration <= 0 {
+		return errors.New("batching max_duration must be positive")
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+// CircuitBreakerConfig defines when requests to an unavailable destination fail fast
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed requests opening the breaker; 0 disables the breaker
+	FailureThreshold int `mapstructure:"failure_threshold"`
+
+	// OpenTimeout is the time the breaker stays open before it lets a probe request pass
+	OpenTimeout time.Duration `mapstructure:"open_timeout"`
+}
+
+// Enabled reports whether the circuit breaker is enabled
+func (cfg *CircuitBreakerConfig) Enabled() bool {
+	return cfg.FailureThreshold > 0
+}
+
+// Validate checks if the circuit breaker configuration is valid
+func (cfg *CircuitBreakerConfig) Validate() error {
+	if cfg.FailureThreshold < 0 {
+		return errors.New("circuit_breaker failure_threshold must not be negative")
+	}
+
+	if cfg.Enabled() && cfg.OpenTimeout <= 0 {
+		return errors.New("circuit_breaker open_timeout must be positive")
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+// ClockSkewConfig defines the correction of span timestamps for the offset of the local clock to the Instana backend
+type ClockSkewConfig struct {
+	// Correct enables shifting span timestamps by the measured offset
+	Correct bool `mapstructure:"correct"`
+
+	// Threshold is the offset that must be exceeded before timestamps are shifted
+	Threshold time.Duration `mapstructure:"threshold"`
+}
+
+// Validate checks if the clock skew configuration is valid
+func (cfg *ClockSkewConfig) Validate() error {
+	if cfg.Threshold < 0 {
+		return errors.New("clock_skew threshold must not be negative")
+	}
+
+	return nil
+}
@@ -2,12 +2,15 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"go.uber.org/zap/zapcore"
 
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
 )
 
 const (
@@ -26,30 +29,221 @@ type Config struct {
 
 	Endpoint string `mapstructure:"endpoint"`
 
+	// FailoverEndpoints are tried in order when the endpoint is unavailable
+	FailoverEndpoints []string `mapstructure:"failover_endpoints"`
+
 	AgentKey string `mapstructure:"agent_key"`
 
+	// AgentKeyFile is a file the agent key is read from instead of AgentKey, re-read when it changes
+	AgentKeyFile string `mapstructure:"agent_key_file"`
+
+	// AgentKeyReloadInterval is the interval in which agent key files are checked for changes
+	AgentKeyReloadInterval time.Duration `mapstructure:"agent_key_reload_interval"`
+
 	confighttp.HTTPClientSettings `mapstructure:",squash"`
 
-	// LogLevel defines log level of the logging exporter; options are debug, info, warn, error.
-	LogLevel zapcore.Level `mapstructure:"loglevel"`
+	// QueueSettings enables a queue of pushed traces consumed by concurrent senders
+	QueueSettings exporterhelper.QueueSettings `mapstructure:"sending_queue"`
+
+	// RetrySettings retries failed exports with a backoff, only the traces of destinations that failed are retried
+	RetrySettings exporterhelper.RetrySettings `mapstructure:"retry_on_failure"`
+
+	// MaxInFlightBytes bounds the bytes of the requests in flight, blocking senders while exceeded; 0 disables the bound
+	MaxInFlightBytes int64 `mapstructure:"max_in_flight_bytes"`
+
+	// LogLevel raises the minimum log level of the exporter above the collector's own level; options are debug, info, warn, error.
+	// Deprecated: the exporter logs through the collector's telemetry logger, configure service::telemetry::logs instead.
+	LogLevel *zapcore.Level `mapstructure:"loglevel"`
+
+	// OperationNaming defines how Instana operation names are built from span attributes
+	OperationNaming OperationNamingConfig `mapstructure:"operation_naming"`
+
+	// ServiceName defines how the Instana service name is resolved from resource and span attributes
+	ServiceName ServiceNameConfig `mapstructure:"service_name"`
+
+	// Correlation defines where end-user and synthetic monitoring correlation data is read from
+	Correlation CorrelationConfig `mapstructure:"correlation"`
+
+	// TraceContinuity defines how traces spanning Instana and OpenTelemetry instrumented services are joined
+	TraceContinuity TraceContinuityConfig `mapstructure:"trace_continuity"`
+
+	// SpanKind defines how OpenTelemetry span kinds map to Instana entry, exit and intermediate spans
+	SpanKind SpanKindConfig `mapstructure:"span_kind"`
+
+	// ErrorClassification defines which spans are counted as erroneous
+	ErrorClassification ErrorClassificationConfig `mapstructure:"error_classification"`
+
+	// Unsampled defines how spans of traces that were not sampled by the tracer are handled
+	Unsampled UnsampledConfig `mapstructure:"unsampled"`
+
+	// Sampling defines the exporter-side trace sampling
+	Sampling SamplingConfig `mapstructure:"sampling"`
+
+	// Exclusions drop spans matching any of the rules
+	Exclusions []ExclusionRule `mapstructure:"exclusions"`
+
+	// RateLimit defines the span budget of the exporter
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+
+	// Batching defines the aggregation of repetitive fast sibling spans into Instana batch spans
+	Batching BatchingConfig `mapstructure:"batching"`
+
+	// RootEntry defines how traces whose root span is not an entry span are turned into Instana calls
+	RootEntry RootEntryConfig `mapstructure:"root_entry"`
+
+	// InternalSpans defines how intermediate spans are handled
+	InternalSpans InternalSpansConfig `mapstructure:"internal_spans"`
+
+	// Routing routes spans to different Instana backends depending on their resource attributes
+	Routing RoutingConfig `mapstructure:"routing"`
+
+	// Failover defines how failed endpoints are probed again
+	Failover FailoverConfig `mapstructure:"failover"`
+
+	// CircuitBreaker defines when requests to an unavailable destination fail fast
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+
+	// ClockSkew defines the correction of span timestamps for the offset of the local clock
+	ClockSkew ClockSkewConfig `mapstructure:"clock_skew"`
+
+	// DeadLetter stores bundles the Instana acceptor rejected permanently
+	DeadLetter FileSinkConfig `mapstructure:"dead_letter"`
+
+	// HealthCheck defines the checks of the connectivity and the agent key of the destinations
+	HealthCheck HealthCheckConfig `mapstructure:"health_check"`
+
+	// DryRun writes the bundles and their headers to files instead of sending them to Instana
+	DryRun FileSinkConfig `mapstructure:"dry_run"`
 }
 
 var _ config.Exporter = (*Config)(nil)
 
+// validateAgentKey checks that exactly one of agent key and agent key file is set
+func validateAgentKey(agentKey string, agentKeyFile string) error {
+	if agentKey == "" && agentKeyFile == "" {
+		return errors.New("no Instana agent key set")
+	}
+
+	if agentKey != "" && agentKeyFile != "" {
+		return errors.New("agent_key and agent_key_file are mutually exclusive")
+	}
+
+	return nil
+}
+
 // Validate checks if the exporter configuration is valid
 func (cfg *Config) Validate() error {
 
-	if cfg.Endpoint == "" {
+	// a dry run sends nothing, so it needs neither endpoint nor agent key
+	if cfg.Endpoint == "" && !cfg.DryRun.Enabled() {
 		return errors.New("no Instana endpoint set")
 	}
 
-	if cfg.AgentKey == "" {
-		return errors.New("no Instana agent key set")
+	if cfg.AgentKey != "" || cfg.AgentKeyFile != "" || !cfg.DryRun.Enabled() {
+		if err := validateAgentKey(cfg.AgentKey, cfg.AgentKeyFile); err != nil {
+			return err
+		}
 	}
 
-	if !(strings.HasPrefix(cfg.Endpoint, "http://") || strings.HasPrefix(cfg.Endpoint, "https://")) {
+	if cfg.AgentKeyReloadInterval <= 0 {
+		return errors.New("agent_key_reload_interval must be positive")
+	}
+
+	if cfg.Endpoint != "" && !(strings.HasPrefix(cfg.Endpoint, "http://") || strings.HasPrefix(cfg.Endpoint, "https://")) {
 		return errors.New("endpoint must start with http:// or https://")
 	}
 
+	if err := validateFailoverEndpoints(cfg.FailoverEndpoints); err != nil {
+		return err
+	}
+
+	if err := cfg.QueueSettings.Validate(); err != nil {
+		return fmt.Errorf("sending_queue: %w", err)
+	}
+
+	if cfg.MaxInFlightBytes < 0 {
+		return errors.New("max_in_flight_bytes must not be negative")
+	}
+
+	if err := cfg.OperationNaming.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.ServiceName.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.SpanKind.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.ErrorClassification.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Unsampled.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Sampling.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.RateLimit.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Batching.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.RootEntry.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.InternalSpans.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Routing.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Failover.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.CircuitBreaker.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.ClockSkew.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.DeadLetter.Validate(); err != nil {
+		return fmt.Errorf("dead_letter: %w", err)
+	}
+
+	if err := cfg.HealthCheck.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.DryRun.Validate(); err != nil {
+		return fmt.Errorf("dry_run: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for i := range cfg.Exclusions {
+		if err := cfg.Exclusions[i].Validate(); err != nil {
+			return fmt.Errorf("exclusion %d: %w", i, err)
+		}
+
+		if names[cfg.Exclusions[i].Name] {
+			return fmt.Errorf("exclusion %d: duplicate name %q", i, cfg.Exclusions[i].Name)
+		}
+		names[cfg.Exclusions[i].Name] = true
+	}
+
 	return nil
 }
@@ -0,0 +1,42 @@
+package config
+
+// CorrelationConfig defines the span attributes end-user monitoring (EUM) and synthetic
+// monitoring correlation is read from. Only entry spans are correlated. Empty names disable a source.
+type CorrelationConfig struct {
+	// TypeAttribute holds the correlation type, e.g. "web" or "mobile"
+	TypeAttribute string `mapstructure:"type_attribute"`
+
+	// IDAttribute holds the correlation ID, e.g. the EUM page load ID
+	IDAttribute string `mapstructure:"id_attribute"`
+
+	// SyntheticAttribute marks a call as synthetic when its value is "true" or "1"
+	SyntheticAttribute string `mapstructure:"synthetic_attribute"`
+
+	// BaggageAttribute holds the captured W3C baggage header. Its "instana-correlation-type", "instana-correlation-id"
+	// and "instana-synthetic" members are used when the attributes themselves are missing.
+	BaggageAttribute string `mapstructure:"baggage_attribute"`
+
+	// LevelHeaderAttribute holds the captured X-INSTANA-L header sent by Instana EUM,
+	// e.g. "1,correlationType=web;correlationId=1234"
+	LevelHeaderAttribute string `mapstructure:"level_header_attribute"`
+
+	// SyntheticHeaderAttribute holds the captured X-INSTANA-SYNTHETIC header sent by Instana synthetic monitoring
+	SyntheticHeaderAttribute string `mapstructure:"synthetic_header_attribute"`
+
+	// UseTraceState takes the correlation ID from the Instana trace ID in the "in" tracestate member
+	// when a correlation type was found but no other source provides an ID
+	UseTraceState bool `mapstructure:"use_trace_state"`
+}
+
+// DefaultCorrelationConfig returns the default correlation attributes, the header attributes follow the
+// OpenTelemetry naming of captured HTTP request headers
+func DefaultCorrelationConfig() CorrelationConfig {
+	return CorrelationConfig{
+		TypeAttribute:            "instana.correlation.type",
+		IDAttribute:              "instana.correlation.id",
+		SyntheticAttribute:       "instana.synthetic",
+		BaggageAttribute:         "http.request.header.baggage",
+		LevelHeaderAttribute:     "http.request.header.x_instana_l",
+		SyntheticHeaderAttribute: "http.request.header.x_instana_synthetic",
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrorClassificationConfig defines which spans are counted as erroneous in addition to spans with an error status
+type ErrorClassificationConfig struct {
+	// HTTPServerStatusCodes lists the http.status_code ranges, e.g. "500-599", marking entry spans as erroneous
+	HTTPServerStatusCodes []string `mapstructure:"http_server_status_codes"`
+
+	// HTTPClientStatusCodes lists the http.status_code ranges marking exit spans as erroneous
+	HTTPClientStatusCodes []string `mapstructure:"http_client_status_codes"`
+
+	// GRPCServerStatusCodes lists the rpc.grpc.status_code ranges marking entry spans as erroneous
+	GRPCServerStatusCodes []string `mapstructure:"grpc_server_status_codes"`
+
+	// GRPCClientStatusCodes lists the rpc.grpc.status_code ranges marking exit spans as erroneous
+	GRPCClientStatusCodes []string `mapstructure:"grpc_client_status_codes"`
+
+	// ExceptionEvents counts every exception event recorded on a span as an error
+	ExceptionEvents bool `mapstructure:"exception_events"`
+
+	// Attributes lists span attributes marking a span as erroneous when set to "true" or "1"
+	Attributes []string `mapstructure:"attributes"`
+}
+
+// StatusCodeRange is an inclusive range of status codes
+type StatusCodeRange struct {
+	Min int
+	Max int
+}
+
+// DefaultErrorClassificationConfig returns the classification recommended by the OpenTelemetry
+// semantic conventions for HTTP and gRPC spans
+func DefaultErrorClassificationConfig() ErrorClassificationConfig {
+	return ErrorClassificationConfig{
+		HTTPServerStatusCodes: []string{"500-599"},
+		HTTPClientStatusCodes: []string{"400-599"},
+		// UNKNOWN, DEADLINE_EXCEEDED, UNIMPLEMENTED, INTERNAL, UNAVAILABLE, DATA_LOSS
+		GRPCServerStatusCodes: []string{"2", "4", "12-15"},
+		GRPCClientStatusCodes: []string{"1-16"},
+		ExceptionEvents:       true,
+		Attributes:            []string{"error"},
+	}
+}
+
+// Validate checks if the error classification configuration is valid
+func (cfg *ErrorClassificationConfig) Validate() error {
+	// checked in a fixed order, so that the first invalid option is reported consistently
+	for _, option := range []struct {
+		name   string
+		ranges []string
+	}{
+		{"http_server_status_codes", cfg.HTTPServerStatusCodes},
+		{"http_client_status_codes", cfg.HTTPClientStatusCodes},
+		{"grpc_server_status_codes", cfg.GRPCServerStatusCodes},
+		{"grpc_client_status_codes", cfg.GRPCClientStatusCodes},
+	} {
+		if _, err := ParseStatusCodeRanges(option.ranges); err != nil {
+			return fmt.Errorf("error_classification %s: %w", option.name, err)
+		}
+	}
+
+	return nil
+}
+
+// ParseStatusCodeRanges parses status code ranges in the form "500-599" or single codes such as "503"
+func ParseStatusCodeRanges(ranges []string) ([]StatusCodeRange, error) {
+	parsed := make([]StatusCodeRange, 0, len(ranges))
+
+	for _, r := range ranges {
+		from, to, isRange := strings.Cut(r, "-")
+		if !isRange {
+			to = from
+		}
+
+		min, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code range %q", r)
+		}
+
+		max, err := strconv.Atoi(strings.TrimSpace(to))
+		if err != nil || max < min {
+			return nil, fmt.Errorf("invalid status code range %q", r)
+		}
+
+		parsed = append(parsed, StatusCodeRange{Min: min, Max: max})
+	}
+
+	return parsed, nil
+}
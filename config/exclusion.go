@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// ExclusionRule drops spans matching all of its conditions, e.g. health checks and metric scrapes
+type ExclusionRule struct {
+	// Name identifies the rule in logs and metrics
+	Name string `mapstructure:"name"`
+
+	// Service matches the Instana service name
+	Service string `mapstructure:"service"`
+
+	// Operation matches the Instana operation name
+	Operation string `mapstructure:"operation"`
+
+	// OperationPattern is a regular expression the Instana operation name must match
+	OperationPattern string `mapstructure:"operation_pattern"`
+
+	// SpanKinds matches spans of the given OpenTelemetry span kinds
+	SpanKinds []string `mapstructure:"span_kinds"`
+
+	// Attributes match span attributes by value or regular expression
+	Attributes []AttributeMatch `mapstructure:"attributes"`
+
+	// MaxDuration matches spans shorter than the given duration
+	MaxDuration time.Duration `mapstructure:"max_duration"`
+
+	// DropDescendants also drops the descendants of matching spans found in the same batch
+	DropDescendants bool `mapstructure:"drop_descendants"`
+}
+
+// AttributeMatch matches a span attribute by its value or a regular expression
+type AttributeMatch struct {
+	Key     string `mapstructure:"key"`
+	Value   string `mapstructure:"value"`
+	Pattern string `mapstructure:"pattern"`
+}
+
+// Validate checks if the exclusion rule is valid
+func (rule *ExclusionRule) Validate() error {
+	if rule.Name == "" {
+		return errors.New("name must not be empty")
+	}
+
+	if rule.Service == "" && rule.Operation == "" && rule.OperationPattern == "" && len(rule.SpanKinds) == 0 &&
+		len(rule.Attributes) == 0 && rule.MaxDuration == 0 {
+		return fmt.Errorf("rule %q has no conditions and would drop all spans", rule.Name)
+	}
+
+	if _, err := regexp.Compile(rule.OperationPattern); err != nil {
+		return fmt.Errorf("rule %q: invalid operation_pattern: %w", rule.Name, err)
+	}
+
+	if err := ValidateSpanKinds(rule.SpanKinds); err != nil {
+		return fmt.Errorf("rule %q: %w", rule.Name, err)
+	}
+
+	for _, attribute := range rule.Attributes {
+		if attribute.Key == "" {
+			return fmt.Errorf("rule %q: attribute key must not be empty", rule.Name)
+		}
+
+		if (attribute.Value == "") == (attribute.Pattern == "") {
+			return fmt.Errorf("rule %q: attribute %q needs either a value or a pattern", rule.Name, attribute.Key)
+		}
+
+		if _, err := regexp.Compile(attribute.Pattern); err != nil {
+			return fmt.Errorf("rule %q: invalid pattern for attribute %q: %w", rule.Name, attribute.Key, err)
+		}
+	}
+
+	if rule.MaxDuration < 0 {
+		return fmt.Errorf("rule %q: max_duration must not be negative", rule.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FailoverConfig defines how endpoints that failed are probed again
+type FailoverConfig struct {
+	// ProbeInterval is the interval in which endpoints that failed are probed in the background
+	ProbeInterval time.Duration `mapstructure:"probe_interval"`
+}
+
+// Validate checks if the failover configuration is valid
+func (cfg *FailoverConfig) Validate() error {
+	if cfg.ProbeInterval <= 0 {
+		return errors.New("failover probe_interval must be positive")
+	}
+
+	return nil
+}
+
+// validateFailoverEndpoints checks that the failover endpoints are HTTP URLs
+func validateFailoverEndpoints(endpoints []string) error {
+	for _, endpoint := range endpoints {
+		if !(strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://")) {
+			return fmt.Errorf("failover endpoint %q must start with http:// or https://", endpoint)
+		}
+	}
+
+	return nil
+}
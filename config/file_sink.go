@@ -0,0 +1,56 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+// FileSinkConfig defines a directory of rotating JSON lines files
+type FileSinkConfig struct {
+	// Directory the files are written to; empty disables the sink
+	Directory string `mapstructure:"directory"`
+
+	// MaxFileSize is the size in bytes at which a file is rotated
+	MaxFileSize int64 `mapstructure:"max_file_size"`
+
+	// MaxFiles is the number of files kept; 0 keeps all files
+	MaxFiles int `mapstructure:"max_files"`
+
+	// MaxAge is the age after which rotated files are removed; 0 keeps files forever
+	MaxAge time.Duration `mapstructure:"max_age"`
+}
+
+// Enabled reports whether the sink is enabled
+func (cfg *FileSinkConfig) Enabled() bool {
+	return cfg.Directory != ""
+}
+
+// Validate checks if the file sink configuration is valid
+func (cfg *FileSinkConfig) Validate() error {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	if cfg.MaxFileSize <= 0 {
+		return errors.New("max_file_size must be positive")
+	}
+
+	if cfg.MaxFiles < 0 {
+		return errors.New("max_files must not be negative")
+	}
+
+	if cfg.MaxAge < 0 {
+		return errors.New("max_age must not be negative")
+	}
+
+	return nil
+}
+
+// DefaultFileSinkConfig returns a disabled sink with limits of 10 files of 10 MiB kept for a week
+func DefaultFileSinkConfig() FileSinkConfig {
+	return FileSinkConfig{
+		MaxFileSize: 10 * 1024 * 1024,
+		MaxFiles:    10,
+		MaxAge:      7 * 24 * time.Hour,
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// StartupCheckOff starts without checking the destinations
+	StartupCheckOff = "off"
+	// StartupCheckWarn logs a warning for destinations that fail the check
+	StartupCheckWarn = "warn"
+	// StartupCheckFail fails the start of the collector if a destination fails the check
+	StartupCheckFail = "fail"
+)
+
+// HealthCheckConfig defines the checks of the connectivity and the agent key of the destinations
+type HealthCheckConfig struct {
+	// Startup is one of off, warn or fail
+	Startup string `mapstructure:"startup"`
+
+	// Interval of the background health check; 0 disables it
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Timeout bounds each probe, independently of the timeout of the requests sending spans
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Validate checks if the health check configuration is valid
+func (cfg *HealthCheckConfig) Validate() error {
+	switch cfg.Startup {
+	case StartupCheckOff, StartupCheckWarn, StartupCheckFail:
+	default:
+		return fmt.Errorf("unknown health_check startup mode %q, must be one of %s, %s, %s", cfg.Startup, StartupCheckOff, StartupCheckWarn, StartupCheckFail)
+	}
+
+	if cfg.Interval < 0 {
+		return errors.New("health_check interval must not be negative")
+	}
+
+	if cfg.Timeout <= 0 {
+		return errors.New("health_check timeout must be positive")
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+)
+
+const (
+	// InternalSpansModeKeep sends intermediate spans like any other span
+	InternalSpansModeKeep = "keep"
+	// InternalSpansModeAnnotate folds intermediate spans into their nearest entry or exit ancestor as annotations
+	InternalSpansModeAnnotate = "annotate"
+	// InternalSpansModeDrop folds intermediate spans into their nearest entry or exit ancestor without a trace
+	InternalSpansModeDrop = "drop"
+)
+
+// InternalSpansConfig defines how intermediate (internal) spans are handled
+type InternalSpansConfig struct {
+	// Mode is one of keep, annotate or drop
+	Mode string `mapstructure:"mode"`
+}
+
+// Validate checks if the internal spans configuration is valid
+func (cfg *InternalSpansConfig) Validate() error {
+	switch cfg.Mode {
+	case InternalSpansModeKeep, InternalSpansModeAnnotate, InternalSpansModeDrop:
+		return nil
+	default:
+		return fmt.Errorf("unknown internal_spans mode %q, must be one of %s, %s, %s", cfg.Mode, InternalSpansModeKeep, InternalSpansModeAnnotate, InternalSpansModeDrop)
+	}
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SpanKinds lists the OpenTelemetry span kind names that can be used in the configuration
+var SpanKinds = []string{"server", "client", "producer", "consumer", "internal", "unspecified"}
+
+// OperationNamingConfig defines how the Instana operation name is built from a span
+type OperationNamingConfig struct {
+	// Rules are evaluated in order, the first rule whose placeholders can all be resolved names the operation.
+	// Spans no rule applies to keep their span name.
+	Rules []OperationNameRule `mapstructure:"rules"`
+
+	// Rewrites are applied in order to the resulting operation name
+	Rewrites []OperationNameRewrite `mapstructure:"rewrites"`
+}
+
+// OperationNameRule builds an operation name from span and resource attributes
+type OperationNameRule struct {
+	// SpanKinds restricts the rule to spans of the given kinds; empty matches all kinds
+	SpanKinds []string `mapstructure:"span_kinds"`
+
+	// Template is the operation name with {attribute} placeholders, e.g. "{http.method} {http.route}".
+	// The {span.name} placeholder resolves to the OpenTelemetry span name.
+	Template string `mapstructure:"template"`
+}
+
+// OperationNameRewrite replaces all matches of a regular expression in the operation name
+type OperationNameRewrite struct {
+	Pattern string `mapstructure:"pattern"`
+
+	// Replacement may reference capture groups of Pattern, e.g. "$1"
+	Replacement string `mapstructure:"replacement"`
+}
+
+// DefaultOperationNameRules returns the rules used when none are configured
+func DefaultOperationNameRules() []OperationNameRule {
+	return []OperationNameRule{
+		{Template: "{http.method} {http.route}"},
+		{Template: "{rpc.service}/{rpc.method}"},
+		{Template: "{messaging.destination}"},
+	}
+}
+
+// Validate checks if the operation naming configuration is valid
+func (cfg *OperationNamingConfig) Validate() error {
+	for i, rule := range cfg.Rules {
+		if err := validateTemplate(rule.Template); err != nil {
+			return fmt.Errorf("operation_naming rule %d: %w", i, err)
+		}
+
+		if err := ValidateSpanKinds(rule.SpanKinds); err != nil {
+			return fmt.Errorf("operation_naming rule %d: %w", i, err)
+		}
+	}
+
+	for i, rewrite := range cfg.Rewrites {
+		if _, err := regexp.Compile(rewrite.Pattern); err != nil {
+			return fmt.Errorf("operation_naming rewrite %d: invalid pattern: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateSpanKinds checks that all kinds are known OpenTelemetry span kind names
+func ValidateSpanKinds(kinds []string) error {
+	for _, kind := range kinds {
+		known := false
+		for _, spanKind := range SpanKinds {
+			if kind == spanKind {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return fmt.Errorf("unknown span kind %q, must be one of %s", kind, strings.Join(SpanKinds, ", "))
+		}
+	}
+
+	return nil
+}
+
+func validateTemplate(template string) error {
+	if template == "" {
+		return errors.New("template must not be empty")
+	}
+
+	open := false
+	for _, c := range template {
+		switch c {
+		case '{':
+			if open {
+				return fmt.Errorf("nested placeholder in template %q", template)
+			}
+			open = true
+		case '}':
+			if !open {
+				return fmt.Errorf("unbalanced placeholder in template %q", template)
+			}
+			open = false
+		}
+	}
+
+	if open {
+		return fmt.Errorf("unterminated placeholder in template %q", template)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RateLimitConfig defines the span budget of the exporter. Spans exceeding the budget are shed,
+// erroneous spans are kept preferentially.
+type RateLimitConfig struct {
+	// SpansPerSecond is the budget for all spans; 0 disables the global limit
+	SpansPerSecond float64 `mapstructure:"spans_per_second"`
+
+	// ServiceSpansPerSecond is the budget for the spans of each service; 0 disables the per-service limit
+	ServiceSpansPerSecond float64 `mapstructure:"service_spans_per_second"`
+
+	// Services overrides ServiceSpansPerSecond for the given service names
+	Services map[string]float64 `mapstructure:"services"`
+
+	// Burst is the time worth of spans a budget can save up while fewer spans arrive,
+	// it must hold at least one span of every budget
+	Burst time.Duration `mapstructure:"burst"`
+}
+
+// Enabled tells whether any limit is configured
+func (cfg *RateLimitConfig) Enabled() bool {
+	return cfg.SpansPerSecond > 0 || cfg.ServiceSpansPerSecond > 0 || len(cfg.Services) > 0
+}
+
+// Validate checks if the rate limit configuration is valid
+func (cfg *RateLimitConfig) Validate() error {
+	if cfg.SpansPerSecond < 0 || cfg.ServiceSpansPerSecond < 0 {
+		return errors.New("rate_limit budgets must not be negative")
+	}
+
+	services := make([]string, 0, len(cfg.Services))
+	for service := range cfg.Services {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	for _, service := range services {
+		if cfg.Services[service] <= 0 {
+			return fmt.Errorf("rate_limit budget of service %q must be positive", service)
+		}
+	}
+
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	if cfg.Burst <= 0 {
+		return errors.New("rate_limit burst must be positive")
+	}
+
+	// a bucket holding less than one span would never admit a span
+	if err := cfg.validateBurst("spans_per_second", cfg.SpansPerSecond); err != nil {
+		return err
+	}
+
+	if err := cfg.validateBurst("service_spans_per_second", cfg.ServiceSpansPerSecond); err != nil {
+		return err
+	}
+
+	for _, service := range services {
+		if err := cfg.validateBurst(fmt.Sprintf("budget of service %q", service), cfg.Services[service]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cfg *RateLimitConfig) validateBurst(name string, budget float64) error {
+	if budget > 0 && budget*cfg.Burst.Seconds() < 1 {
+		return fmt.Errorf("rate_limit %s of %v spans per second needs a burst of at least %v", name, budget,
+			time.Duration(float64(time.Second)/budget))
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+)
+
+const (
+	// RootEntryModeOff leaves traces starting with a non-entry span untouched
+	RootEntryModeOff = "off"
+	// RootEntryModeSynthesize adds a synthetic entry span as parent of the root span
+	RootEntryModeSynthesize = "synthesize"
+	// RootEntryModeRekind turns the root span into an entry span
+	RootEntryModeRekind = "rekind"
+)
+
+// RootEntryConfig defines how traces whose root span is not an entry span, e.g. of batch jobs, are turned into Instana calls
+type RootEntryConfig struct {
+	// Mode is one of off, synthesize or rekind
+	Mode string `mapstructure:"mode"`
+}
+
+// Validate checks if the root entry configuration is valid
+func (cfg *RootEntryConfig) Validate() error {
+	switch cfg.Mode {
+	case RootEntryModeOff, RootEntryModeSynthesize, RootEntryModeRekind:
+		return nil
+	default:
+		return fmt.Errorf("unknown root_entry mode %q, must be one of %s, %s, %s", cfg.Mode, RootEntryModeOff, RootEntryModeSynthesize, RootEntryModeRekind)
+	}
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultDestinationName names the destination defined by the top-level endpoint and agent key
+	DefaultDestinationName = "default"
+)
+
+// Destination is an Instana backend spans can be routed to
+type Destination struct {
+	Endpoint string `mapstructure:"endpoint"`
+
+	// FailoverEndpoints are tried in order when the endpoint is unavailable
+	FailoverEndpoints []string `mapstructure:"failover_endpoints"`
+
+	AgentKey string `mapstructure:"agent_key"`
+
+	// AgentKeyFile is a file the agent key is read from instead of AgentKey, re-read when it changes
+	AgentKeyFile string `mapstructure:"agent_key_file"`
+
+	// Headers are added to the requests sent to this destination
+	Headers map[string]string `mapstructure:"headers"`
+}
+
+// RoutingConfig routes spans to different Instana backends depending on their resource attributes
+type RoutingConfig struct {
+	// Destinations are the named Instana backends in addition to the default one
+	Destinations map[string]Destination `mapstructure:"destinations"`
+
+	// Rules are evaluated in order, the first rule matching a resource selects its destination
+	Rules []RoutingRule `mapstructure:"rules"`
+
+	// DefaultDestination names the destination of resources no rule matches; defaults to the top-level endpoint and agent key
+	DefaultDestination string `mapstructure:"default_destination"`
+}
+
+// RoutingRule selects the destination of resources whose attributes equal all the given values
+type RoutingRule struct {
+	Attributes map[string]string `mapstructure:"attributes"`
+
+	Destination string `mapstructure:"destination"`
+}
+
+// Validate checks if the destination is valid
+func (d *Destination) Validate() error {
+	if d.Endpoint == "" {
+		return errors.New("no Instana endpoint set")
+	}
+
+	if err := validateAgentKey(d.AgentKey, d.AgentKeyFile); err != nil {
+		return err
+	}
+
+	if !(strings.HasPrefix(d.Endpoint, "http://") || strings.HasPrefix(d.Endpoint, "https://")) {
+		return errors.New("endpoint must start with http:// or https://")
+	}
+
+	return validateFailoverEndpoints(d.FailoverEndpoints)
+}
+
+// Validate checks if the routing configuration is valid
+func (cfg *RoutingConfig) Validate() error {
+	for name, destination := range cfg.Destinations {
+		if name == DefaultDestinationName {
+			return fmt.Errorf("routing destination name %q is reserved for the top-level endpoint", name)
+		}
+
+		if err := destination.Validate(); err != nil {
+			return fmt.Errorf("routing destination %q: %w", name, err)
+		}
+	}
+
+	if !cfg.hasDestination(cfg.DefaultDestination) {
+		return fmt.Errorf("routing default_destination %q is not defined", cfg.DefaultDestination)
+	}
+
+	for i, rule := range cfg.Rules {
+		if len(rule.Attributes) == 0 {
+			return fmt.Errorf("routing rule %d: attributes must not be empty", i)
+		}
+
+		if !cfg.hasDestination(rule.Destination) || rule.Destination == "" {
+			return fmt.Errorf("routing rule %d: destination %q is not defined", i, rule.Destination)
+		}
+	}
+
+	return nil
+}
+
+func (cfg *RoutingConfig) hasDestination(name string) bool {
+	if name == "" || name == DefaultDestinationName {
+		return true
+	}
+
+	_, ex := cfg.Destinations[name]
+
+	return ex
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+// SamplingConfig defines the exporter-side trace sampling. Traces containing erroneous or slow calls are always kept.
+type SamplingConfig struct {
+	// Percentage of traces to keep, between 0 and 100. 100 disables sampling.
+	Percentage float64 `mapstructure:"percentage"`
+
+	// SlowThreshold keeps traces containing a span at least this long; 0 disables it
+	SlowThreshold time.Duration `mapstructure:"slow_threshold"`
+
+	// ServiceSlowThresholds overrides SlowThreshold for spans of the given services
+	ServiceSlowThresholds map[string]time.Duration `mapstructure:"service_slow_thresholds"`
+}
+
+// Validate checks if the sampling configuration is valid
+func (cfg *SamplingConfig) Validate() error {
+	if cfg.Percentage < 0 || cfg.Percentage > 100 {
+		return errors.New("sampling percentage must be between 0 and 100")
+	}
+
+	if cfg.SlowThreshold < 0 {
+		return errors.New("sampling slow_threshold must not be negative")
+	}
+
+	return nil
+}
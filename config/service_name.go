@@ -0,0 +1,49 @@
+package config
+
+import (
+	"errors"
+)
+
+const (
+	// ServiceNameSourceScope resolves the service name from the instrumentation scope name
+	ServiceNameSourceScope = "scope.name"
+)
+
+// ServiceNameConfig defines how the Instana service name of a span is resolved
+type ServiceNameConfig struct {
+	// Sources lists resource attributes the service name is taken from, in order of preference.
+	// The special source "scope.name" uses the instrumentation scope name. Values starting with
+	// "unknown_service" are skipped.
+	Sources []string `mapstructure:"sources"`
+
+	// UseNamespace prefixes the resolved service name with the resource's service.namespace, e.g. "shop/checkout"
+	UseNamespace bool `mapstructure:"use_namespace"`
+
+	// Default is used when none of the sources yields a service name
+	Default string `mapstructure:"default"`
+
+	// OverrideAttribute names a span attribute that, when present, overrides the resolved service name for that span
+	OverrideAttribute string `mapstructure:"override_attribute"`
+}
+
+// DefaultServiceNameConfig returns the default service name resolution chain
+func DefaultServiceNameConfig() ServiceNameConfig {
+	return ServiceNameConfig{
+		Sources: []string{
+			"service.name",
+			"k8s.deployment.name",
+			"faas.name",
+			ServiceNameSourceScope,
+		},
+		Default: "unknown-service",
+	}
+}
+
+// Validate checks if the service name configuration is valid
+func (cfg *ServiceNameConfig) Validate() error {
+	if cfg.Default == "" {
+		return errors.New("service_name default must not be empty")
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+)
+
+const (
+	InstanaKindEntry        = "entry"
+	InstanaKindExit         = "exit"
+	InstanaKindIntermediate = "intermediate"
+)
+
+// SpanKindConfig defines how OpenTelemetry span kinds map to Instana entry, exit and intermediate spans
+type SpanKindConfig struct {
+	// Unspecified is the Instana kind of spans with an unspecified span kind
+	Unspecified string `mapstructure:"unspecified"`
+
+	// Overrides replace the default kind mapping for spans of specific instrumentation scopes; the first matching override applies
+	Overrides []SpanKindOverride `mapstructure:"overrides"`
+}
+
+// SpanKindOverride maps spans of an instrumentation scope to an Instana kind
+type SpanKindOverride struct {
+	// Scope is the instrumentation scope name the override applies to
+	Scope string `mapstructure:"scope"`
+
+	// SpanKinds restricts the override to spans of the given OpenTelemetry kinds; empty matches all kinds
+	SpanKinds []string `mapstructure:"span_kinds"`
+
+	// Kind is the Instana kind: entry, exit or intermediate
+	Kind string `mapstructure:"kind"`
+}
+
+// Validate checks if the span kind configuration is valid
+func (cfg *SpanKindConfig) Validate() error {
+	if err := validateInstanaKind(cfg.Unspecified); err != nil {
+		return fmt.Errorf("span_kind unspecified: %w", err)
+	}
+
+	for i, override := range cfg.Overrides {
+		if override.Scope == "" {
+			return fmt.Errorf("span_kind override %d: scope must not be empty", i)
+		}
+
+		if err := ValidateSpanKinds(override.SpanKinds); err != nil {
+			return fmt.Errorf("span_kind override %d: %w", i, err)
+		}
+
+		if err := validateInstanaKind(override.Kind); err != nil {
+			return fmt.Errorf("span_kind override %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func validateInstanaKind(kind string) error {
+	switch kind {
+	case InstanaKindEntry, InstanaKindExit, InstanaKindIntermediate:
+		return nil
+	default:
+		return fmt.Errorf("unknown Instana kind %q, must be one of %s, %s, %s", kind, InstanaKindEntry, InstanaKindExit, InstanaKindIntermediate)
+	}
+}
@@ -0,0 +1,9 @@
+package config
+
+// TraceContinuityConfig defines how traces spanning Instana and OpenTelemetry instrumented services are joined
+type TraceContinuityConfig struct {
+	// ReuseInstanaTraceID replaces the trace ID of all spans of a trace in the batch with the Instana trace ID
+	// found in the "in" tracestate member of its entry span, so that mixed traces render as one trace.
+	// Spans of the trace exported in other batches keep their trace ID, so it is disabled by default.
+	ReuseInstanaTraceID bool `mapstructure:"reuse_instana_trace_id"`
+}
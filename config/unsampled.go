@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+)
+
+const (
+	// UnsampledPolicySend sends unsampled spans like any other span
+	UnsampledPolicySend = "send"
+	// UnsampledPolicyDrop drops unsampled spans
+	UnsampledPolicyDrop = "drop"
+	// UnsampledPolicySynthetic sends unsampled spans marked as synthetic, so they are excluded from the regular call statistics
+	UnsampledPolicySynthetic = "synthetic"
+)
+
+// UnsampledConfig defines how spans of traces that were not sampled by the tracer are handled
+type UnsampledConfig struct {
+	// Policy is one of send, drop or synthetic
+	Policy string `mapstructure:"policy"`
+
+	// PriorityAttribute names the span attribute holding the sampling priority; a priority of 0 or less marks the span as not sampled
+	PriorityAttribute string `mapstructure:"priority_attribute"`
+}
+
+// Validate checks if the unsampled span configuration is valid
+func (cfg *UnsampledConfig) Validate() error {
+	switch cfg.Policy {
+	case UnsampledPolicySend, UnsampledPolicyDrop, UnsampledPolicySynthetic:
+		return nil
+	default:
+		return fmt.Errorf("unknown unsampled policy %q, must be one of %s, %s, %s", cfg.Policy, UnsampledPolicySend, UnsampledPolicyDrop, UnsampledPolicySynthetic)
+	}
+}
@@ -0,0 +1,154 @@
+package instanaexporter
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	instanaConfig "github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/agentkey"
+	"github.com/ibm-observability/instanaexporter/internal/breaker"
+	"github.com/ibm-observability/instanaexporter/internal/failover"
+	"github.com/ibm-observability/instanaexporter/internal/metrics"
+)
+
+// destination is an Instana backend spans are sent to, each with its own HTTP client
+type destination struct {
+	name      string
+	endpoints *failover.Pool
+	agentKey  *agentkey.Key
+	headers   map[string]string
+	client    *http.Client
+	breaker   *breaker.Breaker
+}
+
+// requestHeaders returns the headers of a request to the destination. The send time is set by each request.
+func (d *destination) requestHeaders(hostId string) map[string]string {
+	headers := map[string]string{
+		instanaConfig.HeaderKey:  d.agentKey.Get(),
+		instanaConfig.HeaderHost: hostId,
+	}
+
+	for name, value := range d.headers {
+		headers[name] = value
+	}
+
+	return headers
+}
+
+// formatSendTime formats the x-instana-time header, in milliseconds since the epoch
+func formatSendTime(sendTime time.Time) string {
+	return strconv.FormatInt(sendTime.UnixMilli(), 10)
+}
+
+// newDestinations creates the default destination from the top-level settings, followed by the routing destinations
+func newDestinations(cfg *instanaConfig.Config) ([]*destination, error) {
+	key, err := newAgentKey(cfg.AgentKey, cfg.AgentKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	destinations := []*destination{
+		{
+			name:      instanaConfig.DefaultDestinationName,
+			endpoints: newEndpointPool(cfg.Endpoint, cfg.FailoverEndpoints),
+			agentKey:  key,
+		},
+	}
+
+	names := make([]string, 0, len(cfg.Routing.Destinations))
+	for name := range cfg.Routing.Destinations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		d := cfg.Routing.Destinations[name]
+
+		key, err := newAgentKey(d.AgentKey, d.AgentKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("routing destination %q: %w", name, err)
+		}
+
+		destinations = append(destinations, &destination{
+			name:      name,
+			endpoints: newEndpointPool(d.Endpoint, d.FailoverEndpoints),
+			agentKey:  key,
+			headers:   d.Headers,
+		})
+	}
+
+	return destinations, nil
+}
+
+func newAgentKey(agentKey string, agentKeyFile string) (*agentkey.Key, error) {
+	if agentKeyFile != "" {
+		return agentkey.FromFile(agentKeyFile)
+	}
+
+	return agentkey.Static(agentKey), nil
+}
+
+func newEndpointPool(endpoint string, failoverEndpoints []string) *failover.Pool {
+	endpoints := append([]string{endpoint}, failoverEndpoints...)
+
+	return failover.NewPool(endpoints)
+}
+
+// newDestinationBreaker creates the circuit breaker of a destination, logging and recording its state changes
+func newDestinationBreaker(logger *zap.Logger, recorder *metrics.Recorder, name string, cfg instanaConfig.CircuitBreakerConfig) *breaker.Breaker {
+	return breaker.New(cfg.FailureThreshold, cfg.OpenTimeout, func(state breaker.State) {
+		if state == breaker.StateOpen {
+			logger.Warn("Circuit breaker opened, requests fail fast until the destination recovers", zap.String("destination", name), zap.Duration("open_timeout", cfg.OpenTimeout))
+		} else {
+			logger.Info("Circuit breaker state changed", zap.String("destination", name), zap.Stringer("state", state))
+		}
+		recorder.CircuitBreakerState(name, int(state))
+	})
+}
+
+// router selects the destination of a resource by the configured routing rules
+type router struct {
+	rules              []instanaConfig.RoutingRule
+	defaultDestination string
+}
+
+func newRouter(cfg instanaConfig.RoutingConfig) *router {
+	defaultDestination := cfg.DefaultDestination
+	if defaultDestination == "" {
+		defaultDestination = instanaConfig.DefaultDestinationName
+	}
+
+	return &router{
+		rules:              cfg.Rules,
+		defaultDestination: defaultDestination,
+	}
+}
+
+// route returns the name of the destination for a resource
+func (r *router) route(attributes pcommon.Map) string {
+	for _, rule := range r.rules {
+		if matchesAttributes(attributes, rule.Attributes) {
+			return rule.Destination
+		}
+	}
+
+	return r.defaultDestination
+}
+
+func matchesAttributes(attributes pcommon.Map, expected map[string]string) bool {
+	for key, value := range expected {
+		actual, ex := attributes.Get(key)
+		if !ex || actual.AsString() != value {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,141 @@
+package instanaexporter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// drainer tracks the pushes in flight, so that shutdown can wait for them to complete. Once the shutdown deadline
+// passes, the requests in flight are aborted.
+type drainer struct {
+	mu      sync.RWMutex
+	stopped bool
+	pushes  sync.WaitGroup
+
+	abort     chan struct{}
+	abortOnce sync.Once
+	beginOnce sync.Once
+
+	// stopDone is closed once stop returned, ending the wait for the shutdown context
+	stopDone     chan struct{}
+	stopDoneOnce sync.Once
+
+	// spans sent, failed and rejected since the start, and at the beginning of the shutdown
+	sentSpans     int64
+	failedSpans   int64
+	rejectedSpans int64
+	startSent     int64
+	startFailed   int64
+	startRejected int64
+}
+
+func newDrainer() *drainer {
+	return &drainer{abort: make(chan struct{}), stopDone: make(chan struct{})}
+}
+
+// begin registers a push and reports whether it may proceed; it must be ended with end if it may
+func (d *drainer) begin(spans int) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.stopped || d.aborted() {
+		atomic.AddInt64(&d.rejectedSpans, int64(spans))
+		return false
+	}
+
+	d.pushes.Add(1)
+
+	return true
+}
+
+func (d *drainer) end() {
+	d.pushes.Done()
+}
+
+// abortable derives a context that is also cancelled when the requests in flight are aborted
+func (d *drainer) abortable(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		select {
+		case <-d.abort:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+func (d *drainer) aborted() bool {
+	select {
+	case <-d.abort:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *drainer) abortInFlight() {
+	d.abortOnce.Do(func() {
+		close(d.abort)
+	})
+}
+
+func (d *drainer) spansSent(n int) {
+	atomic.AddInt64(&d.sentSpans, int64(n))
+}
+
+func (d *drainer) spansFailed(n int) {
+	atomic.AddInt64(&d.failedSpans, int64(n))
+}
+
+// beginShutdown starts counting the flushed and abandoned spans, and aborts the requests in flight once the
+// context is done before stop returned. Only the first call has an effect.
+func (d *drainer) beginShutdown(ctx context.Context) {
+	d.beginOnce.Do(func() {
+		d.startSent = atomic.LoadInt64(&d.sentSpans)
+		d.startFailed = atomic.LoadInt64(&d.failedSpans)
+		d.startRejected = atomic.LoadInt64(&d.rejectedSpans)
+
+		go func() {
+			select {
+			case <-ctx.Done():
+				d.abortInFlight()
+			case <-d.stopDone:
+			}
+		}()
+	})
+}
+
+// stop rejects new pushes and waits for the pushes in flight, aborting them once the context is done. It returns
+// the spans sent and the spans failed or rejected since the shutdown began.
+func (d *drainer) stop(ctx context.Context) (flushed int64, abandoned int64) {
+	d.beginShutdown(ctx)
+	defer d.stopDoneOnce.Do(func() {
+		close(d.stopDone)
+	})
+
+	d.mu.Lock()
+	d.stopped = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.pushes.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		d.abortInFlight()
+		<-done
+	}
+
+	flushed = atomic.LoadInt64(&d.sentSpans) - d.startSent
+	abandoned = atomic.LoadInt64(&d.failedSpans) - d.startFailed + atomic.LoadInt64(&d.rejectedSpans) - d.startRejected
+
+	return flushed, abandoned
+}
@@ -0,0 +1,48 @@
+package instanaexporter
+
+import (
+	"encoding/json"
+	"time"
+
+	instanaConfig "github.com/ibm-observability/instanaexporter/config"
+)
+
+const (
+	// dryRunFilePrefix is the prefix of the files written in a dry run
+	dryRunFilePrefix = "instana-dry-run"
+
+	// redactedAgentKey replaces the agent key in the headers written in a dry run
+	redactedAgentKey = "<redacted>"
+)
+
+// dryRunRecord is a request written to a file instead of being sent to Instana
+type dryRunRecord struct {
+	Time        time.Time `json:"time"`
+	Destination string    `json:"destination"`
+
+	// Headers are the request headers, with the agent key redacted
+	Headers map[string]string `json:"headers"`
+
+	// Bundle is the exact request body
+	Bundle json.RawMessage `json:"bundle"`
+}
+
+// writeDryRun writes a request instead of sending it
+func (e *instanaExporter) writeDryRun(d *destination, headers map[string]string, timeOffset time.Duration, bundle []byte) error {
+	record := dryRunRecord{
+		Time:        time.Now(),
+		Destination: d.name,
+		Headers:     make(map[string]string, len(headers)),
+		Bundle:      bundle,
+	}
+
+	for name, value := range headers {
+		record.Headers[name] = value
+	}
+	record.Headers[instanaConfig.HeaderTime] = formatSendTime(record.Time.Add(timeOffset))
+	if record.Headers[instanaConfig.HeaderKey] != "" {
+		record.Headers[instanaConfig.HeaderKey] = redactedAgentKey
+	}
+
+	return e.dryRun.Write(record)
+}
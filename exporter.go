@@ -5,11 +5,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
@@ -19,89 +24,532 @@ import (
 	"go.opentelemetry.io/collector/pdata/ptrace"
 
 	instanaConfig "github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/clockskew"
 	"github.com/ibm-observability/instanaexporter/internal/converter"
 	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"github.com/ibm-observability/instanaexporter/internal/deadletter"
+	"github.com/ibm-observability/instanaexporter/internal/filesink"
+	"github.com/ibm-observability/instanaexporter/internal/inflight"
+	"github.com/ibm-observability/instanaexporter/internal/metrics"
 	"github.com/ibm-observability/instanaexporter/internal/otlptext"
+	"github.com/ibm-observability/instanaexporter/internal/ratelimit"
+	"github.com/ibm-observability/instanaexporter/internal/sampling"
+)
+
+// errShuttingDown is returned for traces pushed after the shutdown began
+var errShuttingDown = errors.New("the exporter is shutting down")
+
+// errCircuitOpen is returned without sending a request while the circuit breaker of a destination is open
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+const (
+	// payloadLogSampleTick is the interval in which at most one payload dump per message is logged
+	payloadLogSampleTick = time.Second
+
+	// maxResponseSize limits the part of an unsuccessful response kept for the dead-letter files
+	maxResponseSize = 4 * 1024
 )
 
 type instanaExporter struct {
 	config          *instanaConfig.Config
-	client          *http.Client
+	destinations    []*destination
+	router          *router
 	logger          *zap.Logger
+	payloadLogger   *zap.Logger
+	converter       converter.Converter
+	batchProcessors []converter.BatchProcessor
+	recorder        *metrics.Recorder
+	clockSkew       *clockskew.Estimator
+	deadLetter      *filesink.Writer
+	dryRun          *filesink.Writer
+	inFlight        *inflight.Gate
+	drainer         *drainer
+	healthChecker   *healthChecker
+	failoverProber  *failoverProber
+	// correctingSkew is 1 while span timestamps are shifted by the clock offset
+	correctingSkew  uint32
 	tracesMarshaler ptrace.Marshaler
 	settings        component.TelemetrySettings
 	userAgent       string
 }
 
-func (e *instanaExporter) start(_ context.Context, host component.Host) error {
-	client, err := e.config.HTTPClientSettings.ToClient(host, e.settings)
-	if err != nil {
+func (e *instanaExporter) start(ctx context.Context, host component.Host) error {
+	for _, d := range e.destinations {
+		client, err := e.config.HTTPClientSettings.ToClient(host, e.settings)
+		if err != nil {
+			return err
+		}
+		d.client = client
+	}
+
+	if err := e.checkStartup(ctx); err != nil {
 		return err
 	}
-	e.client = client
+
+	// watch the key files only once the start succeeded, a failed start is not shut down
+	for _, d := range e.destinations {
+		d := d
+		d.agentKey.Watch(e.config.AgentKeyReloadInterval, func(changed bool, err error) {
+			if err != nil {
+				e.logger.Warn("Failed to reload the agent key, keeping the previous key", zap.String("destination", d.name), zap.Error(err))
+				return
+			}
+			e.logger.Info("Agent key rotated", zap.String("destination", d.name))
+		})
+	}
+
+	e.healthChecker.start()
+	e.failoverProber.start()
+
 	return nil
 }
 
+// beginShutdown bounds draining the pushes in flight, including the queued ones, by the shutdown context
+func (e *instanaExporter) beginShutdown(ctx context.Context) {
+	e.drainer.beginShutdown(ctx)
+}
+
+// shutdown rejects new traces and waits for the pushes in flight until the context is done, then aborts them
+func (e *instanaExporter) shutdown(ctx context.Context) error {
+	flushed, abandoned := e.drainer.stop(ctx)
+	if abandoned > 0 {
+		e.logger.Warn("Shut down, abandoning spans not sent in time", zap.Int64("flushed_spans", flushed), zap.Int64("abandoned_spans", abandoned))
+	} else {
+		e.logger.Info("Shut down", zap.Int64("flushed_spans", flushed), zap.Int64("abandoned_spans", abandoned))
+	}
+
+	e.healthChecker.stop()
+	e.failoverProber.stop()
+
+	for _, d := range e.destinations {
+		d.agentKey.Stop()
+	}
+
+	var errs error
+	if e.deadLetter != nil {
+		errs = multierr.Append(errs, e.deadLetter.Close())
+	}
+	if e.dryRun != nil {
+		errs = multierr.Append(errs, e.dryRun.Close())
+	}
+	return errs
+}
+
+// exportGroup collects the spans routed to one destination
+type exportGroup struct {
+	spans  []model.Span
+	hostId string
+	// resources are the indexes of the resource spans routed to the destination
+	resources []int
+}
+
 func (e *instanaExporter) pushConvertedTraces(ctx context.Context, td ptrace.Traces) error {
-	e.logger.Info("TracesExporter", zap.Int("#spans", td.SpanCount()))
-	if !e.logger.Core().Enabled(zapcore.DebugLevel) {
-		return nil
+	e.logger.Debug("Exporting traces", zap.Int("#spans", td.SpanCount()))
+
+	if ce := e.payloadLogger.Check(zapcore.DebugLevel, "Received traces"); ce != nil {
+		buf, err := e.tracesMarshaler.MarshalTraces(td)
+		if err != nil {
+			e.logger.Debug("Failed to marshal traces for logging", zap.Error(err))
+		} else {
+			ce.Write(zap.ByteString("traces", buf))
+		}
 	}
 
-	buf, err := e.tracesMarshaler.MarshalTraces(td)
-	if err != nil {
-		return err
+	if !e.drainer.begin(td.SpanCount()) {
+		return errShuttingDown
+	}
+	defer e.drainer.end()
+
+	ctx, cancel := e.drainer.abortable(ctx)
+	defer cancel()
+
+	if e.allBreakersOpen() {
+		// skip the conversion, no destination would accept the spans
+		e.drainer.spansFailed(td.SpanCount())
+		return errCircuitOpen
 	}
-	e.logger.Debug(string(buf))
 
-	converter := converter.NewConvertAllConverter(e.logger)
-	spans := make([]model.Span, 0)
+	groups := make(map[string]*exportGroup)
 
-	hostId := ""
 	resourceSpans := td.ResourceSpans()
 	for i := 0; i < resourceSpans.Len(); i++ {
 		resSpan := resourceSpans.At(i)
 
 		resource := resSpan.Resource()
 
+		name := e.router.route(resource.Attributes())
+		group, ex := groups[name]
+		if !ex {
+			group = &exportGroup{spans: make([]model.Span, 0)}
+			groups[name] = group
+		}
+		group.resources = append(group.resources, i)
+
 		hostIdAttr, ex := resource.Attributes().Get(instanaConfig.AttributeInstanaHostID)
 		if ex {
-			hostId = hostIdAttr.StringVal()
+			group.hostId = hostIdAttr.StringVal()
 		}
 
 		ilSpans := resSpan.ScopeSpans()
 		for j := 0; j < ilSpans.Len(); j++ {
-			converterBundle := converter.ConvertSpans(resource.Attributes(), ilSpans.At(j).Spans())
+			ilSpan := ilSpans.At(j)
+			converterBundle := e.converter.ConvertSpans(resource.Attributes(), ilSpan.Scope(), ilSpan.Spans())
 
-			spans = append(spans, converterBundle.Spans...)
+			group.spans = append(group.spans, converterBundle.Spans...)
 		}
 	}
 
-	bundle := model.Bundle{Spans: spans}
+	// destinations are sent to concurrently, each reporting its own error
+	errs := make([]error, len(e.destinations))
+	var wg sync.WaitGroup
+	for i, d := range e.destinations {
+		group, ex := groups[d.name]
+		if !ex {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, d *destination, group *exportGroup) {
+			defer wg.Done()
+			errs[i] = e.send(ctx, d, group)
+		}(i, d, group)
+	}
+	wg.Wait()
+
+	return e.exportResult(td, groups, errs)
+}
+
+// exportResult combines the errors of the destinations. If any destination failed with a retryable error,
+// the result is retryable and carries only the traces routed to those destinations, so that a retry does not
+// send the spans to the destinations that accepted or permanently rejected them again.
+func (e *instanaExporter) exportResult(td ptrace.Traces, groups map[string]*exportGroup, errs []error) error {
+	var retryable, permanent []error
+	failed := ptrace.NewTraces()
+
+	for i, d := range e.destinations {
+		err := errs[i]
+		if err == nil {
+			continue
+		}
 
-	if len(bundle.Spans) <= 0 {
+		if consumererror.IsPermanent(err) {
+			permanent = append(permanent, err)
+			continue
+		}
+
+		retryable = append(retryable, err)
+		for _, resource := range groups[d.name].resources {
+			td.ResourceSpans().At(resource).CopyTo(failed.ResourceSpans().AppendEmpty())
+		}
+	}
+
+	if len(retryable) == 0 {
+		return multierr.Combine(permanent...)
+	}
+
+	// a permanent rejection by one destination must not keep the others from being retried
+	for _, err := range permanent {
+		retryable = append(retryable, errors.New(err.Error()))
+	}
+
+	e.logger.Debug("Export failed for some destinations, only their traces are returned for a retry",
+		zap.Int("retryable_destinations", len(retryable)-len(permanent)),
+		zap.Int("permanent_destinations", len(permanent)),
+		zap.Int("#spans", failed.SpanCount()))
+
+	return consumererror.NewTraces(multierr.Combine(retryable...), failed)
+}
+
+// send processes the spans routed to a destination and sends them as one bundle
+func (e *instanaExporter) send(ctx context.Context, d *destination, group *exportGroup) error {
+	spans := group.spans
+	for _, processor := range e.batchProcessors {
+		spans = processor.ProcessSpans(spans)
+	}
+
+	if len(spans) <= 0 {
 		// skip exporting, nothing to do
 		return nil
 	}
 
+	// the send time of each request is shifted by the same offset as the span timestamps
+	var offset time.Duration
+	if skew, correct := e.clockSkewCorrection(); correct {
+		offset = skew
+		shiftTimestamps(spans, offset)
+	}
+
+	return e.sendBundle(ctx, d, group.hostId, spans, offset)
+}
+
+// sendBundle sends the spans as one bundle. A bundle the acceptor rejects as too large is split into halves that are
+// sent on their own, down to single spans; bundles that are still rejected permanently are dead-lettered.
+func (e *instanaExporter) sendBundle(ctx context.Context, d *destination, hostId string, spans []model.Span, timeOffset time.Duration) error {
+	bundle := model.Bundle{Spans: spans}
+	headers := d.requestHeaders(hostId)
+
 	req, err := bundle.Marshal()
+	if err != nil {
+		err = consumererror.NewPermanent(fmt.Errorf("failed to marshal the bundle of destination %q: %w", d.name, err))
+		e.logger.Error("Failed to marshal the bundle, dropping its spans", zap.String("destination", d.name), zap.Int("#spans", len(spans)), zap.Error(err))
+		e.recorder.SpansDropped(metrics.ReasonMarshal, len(spans))
+		e.drainer.spansFailed(len(spans))
+		e.writeDeadLetter(d, headers, spans, nil, err)
+		return err
+	}
+
+	err = e.sendRequest(ctx, d, headers, timeOffset, req)
 
-	e.logger.Debug(string(req))
+	if isTooLarge(err) && len(spans) > 1 {
+		e.logger.Info("Bundle too large, splitting it", zap.String("destination", d.name), zap.Int("#spans", len(spans)))
+
+		half := len(spans) / 2
+		return splitResult(
+			e.sendBundle(ctx, d, hostId, spans[:half], timeOffset),
+			e.sendBundle(ctx, d, hostId, spans[half:], timeOffset),
+		)
+	}
 
 	if err != nil {
-		return consumererror.NewPermanent(err)
+		if consumererror.IsPermanent(err) {
+			e.writeDeadLetter(d, headers, spans, req, err)
+		}
+
+		e.requestDone(d, metrics.ResultFailure, len(spans))
+		return fmt.Errorf("failed to export to destination %q: %w", d.name, err)
+	}
+
+	e.requestDone(d, metrics.ResultSuccess, len(spans))
+
+	return nil
+}
+
+// sendRequest sends a marshalled bundle to the destination, or writes it to the dry-run files
+func (e *instanaExporter) sendRequest(ctx context.Context, d *destination, headers map[string]string, timeOffset time.Duration, req []byte) error {
+	// wait for earlier requests to complete while too many bytes are in flight
+	size := int64(len(req))
+	if err := e.acquireInFlight(ctx, size); err != nil {
+		return err
+	}
+	// released before a bundle that is too large is split, so that its halves fit into the bound
+	defer e.releaseInFlight(size)
+
+	if ce := e.payloadLogger.Check(zapcore.DebugLevel, "Sending bundle"); ce != nil {
+		ce.Write(zap.String("destination", d.name), zap.ByteString("bundle", req))
+	}
+
+	if e.dryRun != nil {
+		if err := e.writeDryRun(d, headers, timeOffset, req); err != nil {
+			return fmt.Errorf("failed to write the bundle: %w", err)
+		}
+
+		return nil
+	}
+
+	if !d.breaker.Allow() {
+		return errCircuitOpen
+	}
+
+	err := e.exportWithFailover(ctx, d, headers, timeOffset, req)
+	if isUnauthorized(err) && e.refreshAgentKey(d, headers[instanaConfig.HeaderKey]) {
+		// the key was rotated since the request was made, retry once with the new key
+		headers[instanaConfig.HeaderKey] = d.agentKey.Get()
+		err = e.exportWithFailover(ctx, d, headers, timeOffset, req)
 	}
 
-	headers := map[string]string{
-		instanaConfig.HeaderKey:  e.config.AgentKey,
-		instanaConfig.HeaderHost: hostId,
-		instanaConfig.HeaderTime: "0",
+	if err != nil && !consumererror.IsPermanent(err) {
+		d.breaker.Failure()
+	} else {
+		// a rejected request proves the destination reachable as well
+		d.breaker.Success()
 	}
 
-	return e.export(ctx, e.config.Endpoint, headers, req)
+	return err
 }
 
-func newInstanaExporter(logger *zap.Logger, cfg config.Exporter, set component.ExporterCreateSettings) (*instanaExporter, error) {
+// splitResult combines the errors of the halves of a split bundle. If either half failed with a retryable error, the
+// result is retryable, so that a permanently rejected half does not keep the other from being retried.
+func splitResult(errs ...error) error {
+	retryable := false
+	for _, err := range errs {
+		if err != nil && !consumererror.IsPermanent(err) {
+			retryable = true
+		}
+	}
+
+	if retryable {
+		for i, err := range errs {
+			if consumererror.IsPermanent(err) {
+				errs[i] = errors.New(err.Error())
+			}
+		}
+	}
+
+	return multierr.Combine(errs...)
+}
+
+// requestDone records the result of a request
+func (e *instanaExporter) requestDone(d *destination, result string, spans int) {
+	e.recorder.Request(d.name, result, spans)
+
+	if result == metrics.ResultSuccess {
+		e.drainer.spansSent(spans)
+	} else {
+		e.drainer.spansFailed(spans)
+	}
+}
+
+// acquireInFlight blocks until the request fits into the bound of bytes in flight
+func (e *instanaExporter) acquireInFlight(ctx context.Context, size int64) error {
+	if e.inFlight == nil {
+		return nil
+	}
+
+	if err := e.inFlight.Acquire(ctx, size); err != nil {
+		return err
+	}
+	e.recorder.InFlightBytes(e.inFlight.InFlight())
+
+	return nil
+}
+
+func (e *instanaExporter) releaseInFlight(size int64) {
+	if e.inFlight == nil {
+		return
+	}
+
+	e.inFlight.Release(size)
+	e.recorder.InFlightBytes(e.inFlight.InFlight())
+}
+
+// writeDeadLetter stores a permanently rejected bundle, if the dead-letter files are enabled. A bundle that could not
+// be marshalled is stored with the IDs of its spans instead, so that the loss can be audited.
+func (e *instanaExporter) writeDeadLetter(d *destination, headers map[string]string, spans []model.Span, bundle []byte, err error) {
+	if e.deadLetter == nil {
+		return
+	}
+
+	record := deadletter.Record{
+		Time:        time.Now(),
+		Destination: d.name,
+		Headers:     make(map[string]string, len(headers)),
+		Error:       err.Error(),
+		Spans:       len(spans),
+		Bundle:      bundle,
+	}
+
+	if bundle == nil {
+		record.SpanIDs = make([]deadletter.SpanID, 0, len(spans))
+		for _, span := range spans {
+			record.SpanIDs = append(record.SpanIDs, deadletter.SpanID{TraceID: span.TraceID, SpanID: span.SpanID})
+		}
+	}
+
+	for name, value := range headers {
+		if name != instanaConfig.HeaderKey {
+			record.Headers[name] = value
+		}
+	}
+
+	var status *statusError
+	if errors.As(err, &status) {
+		record.StatusCode = status.statusCode
+		record.Response = status.response
+	}
+
+	if err := e.deadLetter.Write(record); err != nil {
+		e.logger.Error("Failed to write a bundle to the dead-letter files, it is lost", zap.String("destination", d.name), zap.Error(err))
+		return
+	}
+
+	e.logger.Warn("Bundle failed permanently, written to the dead-letter files", zap.String("destination", d.name), zap.Error(err))
+	e.recorder.DeadLettered(d.name)
+}
+
+// clockSkewCorrection returns the offset span timestamps are shifted by, if the correction is enabled and the
+// estimated offset exceeds the threshold
+func (e *instanaExporter) clockSkewCorrection() (time.Duration, bool) {
+	if !e.config.ClockSkew.Correct {
+		return 0, false
+	}
+
+	offset, sampled := e.clockSkew.Offset()
+	correct := sampled && (offset > e.config.ClockSkew.Threshold || -offset > e.config.ClockSkew.Threshold)
+
+	var state uint32
+	if correct {
+		state = 1
+	}
+	if atomic.SwapUint32(&e.correctingSkew, state) != state {
+		if correct {
+			e.logger.Warn("Local clock is off, shifting span timestamps", zap.Duration("offset", offset))
+		} else {
+			e.logger.Info("Local clock is back in sync, no longer shifting span timestamps", zap.Duration("offset", offset))
+		}
+	}
+
+	return offset, correct
+}
+
+// shiftTimestamps moves the spans by the offset
+func shiftTimestamps(spans []model.Span, offset time.Duration) {
+	for i := range spans {
+		spans[i].Timestamp = uint64(int64(spans[i].Timestamp) + offset.Milliseconds())
+	}
+}
+
+// refreshAgentKey reloads the agent key of a destination after the given key was rejected and reports whether the
+// current key differs from it. The key may also have been rotated by the periodic reload since the request was made.
+func (e *instanaExporter) refreshAgentKey(d *destination, rejectedKey string) bool {
+	if _, err := d.agentKey.Reload(); err != nil {
+		e.logger.Warn("Failed to reload the rejected agent key", zap.String("destination", d.name), zap.Error(err))
+	}
+
+	if d.agentKey.Get() == rejectedKey {
+		return false
+	}
+
+	e.logger.Info("Agent key rotated after it was rejected", zap.String("destination", d.name))
+
+	return true
+}
+
+// allBreakersOpen reports whether the circuit breakers of all destinations reject requests
+func (e *instanaExporter) allBreakersOpen() bool {
+	for _, d := range e.destinations {
+		if !d.breaker.Rejecting() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// exportWithFailover sends the request to the endpoints of the destination in order, until one is available
+func (e *instanaExporter) exportWithFailover(ctx context.Context, d *destination, header map[string]string, timeOffset time.Duration, request []byte) error {
+	var err error
+	for _, endpoint := range d.endpoints.Attempts() {
+		err = e.export(ctx, d.client, endpoint, header, timeOffset, request)
+
+		var unavailable *unavailableError
+		if !errors.As(err, &unavailable) || ctx.Err() != nil {
+			if err == nil && d.endpoints.Succeeded(endpoint) {
+				e.logger.Info("Switched to another Instana endpoint", zap.String("destination", d.name), zap.String("endpoint", endpoint))
+				e.recorder.EndpointSwitch(d.name, endpoint)
+			}
+
+			return err
+		}
+
+		e.logger.Warn("Instana endpoint unavailable", zap.String("destination", d.name), zap.String("endpoint", endpoint), zap.Error(err))
+		e.recorder.EndpointFailure(d.name, endpoint)
+		d.endpoints.Failed(endpoint)
+	}
+
+	return err
+}
+
+func newInstanaExporter(cfg config.Exporter, set component.ExporterCreateSettings) (*instanaExporter, error) {
 	iCfg := cfg.(*instanaConfig.Config)
 
 	if iCfg.Endpoint != "" {
@@ -113,15 +561,148 @@ func newInstanaExporter(logger *zap.Logger, cfg config.Exporter, set component.E
 
 	userAgent := fmt.Sprintf("%s/%s (%s/%s)", set.BuildInfo.Description, set.BuildInfo.Version, runtime.GOOS, runtime.GOARCH)
 
-	return &instanaExporter{
+	logger := newExporterLogger(iCfg, set.Logger)
+
+	if err := metrics.RegisterViews(); err != nil {
+		return nil, fmt.Errorf("failed to register metric views: %w", err)
+	}
+
+	recorder := metrics.NewRecorder(iCfg.ID())
+
+	spanConverter, err := converter.NewConvertAllConverter(logger, recorder, iCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	batchProcessors, err := newBatchProcessors(logger, recorder, iCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	destinations, err := newDestinations(iCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var dryRun *filesink.Writer
+	if iCfg.DryRun.Enabled() {
+		dryRun, err = newFileSink(iCfg.DryRun, dryRunFilePrefix)
+		if err != nil {
+			return nil, err
+		}
+		logger.Warn("Dry run, bundles are written to files instead of being sent to Instana", zap.String("directory", iCfg.DryRun.Directory))
+	}
+
+	var inFlight *inflight.Gate
+	if iCfg.MaxInFlightBytes > 0 {
+		inFlight = inflight.NewGate(iCfg.MaxInFlightBytes)
+	}
+
+	var deadLetter *filesink.Writer
+	if iCfg.DeadLetter.Enabled() {
+		deadLetter, err = newFileSink(iCfg.DeadLetter, deadletter.FilePrefix)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if iCfg.CircuitBreaker.Enabled() {
+		for _, d := range destinations {
+			d.breaker = newDestinationBreaker(logger, recorder, d.name, iCfg.CircuitBreaker)
+		}
+	}
+
+	e := &instanaExporter{
 		config:          iCfg,
+		destinations:    destinations,
+		router:          newRouter(iCfg.Routing),
 		logger:          logger,
+		payloadLogger:   newPayloadLogger(logger),
+		converter:       spanConverter,
+		batchProcessors: batchProcessors,
+		recorder:        recorder,
+		clockSkew:       clockskew.NewEstimator(),
+		deadLetter:      deadLetter,
+		dryRun:          dryRun,
+		inFlight:        inFlight,
+		drainer:         newDrainer(),
 		tracesMarshaler: otlptext.NewTextTracesMarshaler(),
+		settings:        set.TelemetrySettings,
 		userAgent:       userAgent,
-	}, nil
+	}
+
+	healthCheckInterval := iCfg.HealthCheck.Interval
+	if dryRun != nil {
+		// nothing is sent, so there is nothing to check
+		healthCheckInterval = 0
+	}
+	e.healthChecker = newHealthChecker(e, healthCheckInterval)
+
+	failoverProbeInterval := iCfg.Failover.ProbeInterval
+	if dryRun != nil {
+		failoverProbeInterval = 0
+	}
+	e.failoverProber = newFailoverProber(e, failoverProbeInterval)
+
+	return e, nil
+}
+
+// newBatchProcessors creates the processors applied to the converted spans of every batch: first the
+// converter's processors that complete the spans, then the exporter's processors that reduce the volume
+func newBatchProcessors(logger *zap.Logger, recorder *metrics.Recorder, cfg *instanaConfig.Config) ([]converter.BatchProcessor, error) {
+	processors, err := converter.NewBatchProcessors(logger, recorder, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Sampling.Percentage < 100 {
+		processors = append(processors, sampling.NewSampler(logger, recorder, cfg.Sampling))
+	}
+
+	if cfg.RateLimit.Enabled() {
+		processors = append(processors, ratelimit.NewLimiter(logger, recorder, cfg.RateLimit))
+	}
+
+	return processors, nil
+}
+
+// newFileSink creates a writer of rotating JSON lines files
+func newFileSink(cfg instanaConfig.FileSinkConfig, prefix string) (*filesink.Writer, error) {
+	return filesink.NewWriter(cfg.Directory, prefix, cfg.MaxFileSize, cfg.MaxFiles, cfg.MaxAge)
+}
+
+// newExporterLogger derives the exporter logger from the collector's telemetry logger,
+// which already carries the component kind and name fields.
+func newExporterLogger(cfg *instanaConfig.Config, logger *zap.Logger) *zap.Logger {
+	if cfg.LogLevel == nil {
+		return logger
+	}
+
+	logger.Warn("The loglevel setting is deprecated, configure the collector's service::telemetry::logs::level instead",
+		zap.Stringer("loglevel", cfg.LogLevel))
+
+	// zap can only raise the level of an existing core, a lower level would be a no-op
+	if !logger.Core().Enabled(*cfg.LogLevel) {
+		logger.Warn("The loglevel setting is below the collector's log level and has no effect",
+			zap.Stringer("loglevel", cfg.LogLevel))
+
+		return logger
+	}
+
+	return logger.WithOptions(zap.IncreaseLevel(*cfg.LogLevel))
 }
 
-func (e *instanaExporter) export(ctx context.Context, url string, header map[string]string, request []byte) error {
+// newPayloadLogger creates the debug channel used to dump trace and bundle payloads. It is sampled
+// so that enabling debug logging does not flood the collector logs with every payload.
+func newPayloadLogger(logger *zap.Logger) *zap.Logger {
+	return logger.Named("payload").WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSamplerWithOptions(core, payloadLogSampleTick, 1, 0)
+	}))
+}
+
+// export sends the request to the endpoint. Its x-instana-time header is the time the request is made, shifted by
+// the time offset.
+func (e *instanaExporter) export(ctx context.Context, client *http.Client, url string, header map[string]string, timeOffset time.Duration, request []byte) error {
 	url = strings.TrimSuffix(url, "/") + "/bundle"
 
 	e.logger.Debug("Preparing to make HTTP request", zap.String("url", url))
@@ -138,9 +719,18 @@ func (e *instanaExporter) export(ctx context.Context, url string, header map[str
 		req.Header.Set(name, value)
 	}
 
-	resp, err := e.client.Do(req)
+	sent := time.Now()
+	req.Header.Set(instanaConfig.HeaderTime, formatSendTime(sent.Add(timeOffset)))
+
+	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to make an HTTP request: %w", err)
+		return &unavailableError{err: fmt.Errorf("failed to make an HTTP request: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	e.clockSkew.Observe(sent, time.Now(), resp.Header.Get("Date"))
+	if offset, sampled := e.clockSkew.Offset(); sampled {
+		e.recorder.ClockOffset(offset)
 	}
 
 	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
@@ -148,5 +738,61 @@ func (e *instanaExporter) export(ctx context.Context, url string, header map[str
 		return nil
 	}
 
-	return nil
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	err = &statusError{statusCode: resp.StatusCode, response: string(response)}
+
+	// Server errors make another endpoint worth trying
+	if resp.StatusCode >= 500 {
+		return &unavailableError{err: err}
+	}
+
+	// Throttling is transient, any other rejection would repeat
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return err
+	}
+
+	return consumererror.NewPermanent(err)
+}
+
+// unavailableError is returned for connection errors and server errors of an endpoint
+type unavailableError struct {
+	err error
+}
+
+func (e *unavailableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *unavailableError) Unwrap() error {
+	return e.err
+}
+
+// statusError is returned when the Instana acceptor responds with an unsuccessful HTTP status
+type statusError struct {
+	statusCode int
+	response   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("the Instana acceptor responded with HTTP status %d", e.statusCode)
+}
+
+// isTooLarge reports whether the bundle was rejected for its size
+func isTooLarge(err error) bool {
+	var status *statusError
+	if !errors.As(err, &status) {
+		return false
+	}
+
+	return status.statusCode == http.StatusRequestEntityTooLarge
+}
+
+// isUnauthorized reports whether the agent key was rejected
+func isUnauthorized(err error) bool {
+	var status *statusError
+	if !errors.As(err, &status) {
+		return false
+	}
+
+	return status.statusCode == http.StatusUnauthorized || status.statusCode == http.StatusForbidden
 }
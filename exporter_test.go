@@ -0,0 +1,1142 @@
+package instanaexporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/breaker"
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"github.com/ibm-observability/instanaexporter/internal/deadletter"
+	"github.com/ibm-observability/instanaexporter/internal/filesink"
+)
+
+// acceptor records the agent keys of the bundles it receives and responds with a fixed status
+type acceptor struct {
+	server *httptest.Server
+	status int
+	// validKey rejects requests with other agent keys, if set
+	validKey string
+	// clockOffset shifts the Date header of the responses
+	clockOffset time.Duration
+	// delay holds each response back
+	delay time.Duration
+	// maxBodySize rejects larger bodies with 413, if set
+	maxBodySize int
+
+	mu         sync.Mutex
+	agentKeys  []string
+	probes     int
+	lastHeader http.Header
+	lastBody   []byte
+	active     int
+	maxActive  int
+}
+
+func newAcceptor(t *testing.T, status int) *acceptor {
+	a := &acceptor{status: status}
+	a.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		a.mu.Lock()
+		a.agentKeys = append(a.agentKeys, r.Header.Get(config.HeaderKey))
+		if string(body) == "{}" {
+			a.probes++
+		}
+		a.lastHeader = r.Header
+		a.lastBody = body
+		if a.clockOffset != 0 {
+			w.Header().Set("Date", time.Now().Add(a.clockOffset).UTC().Format(http.TimeFormat))
+		}
+		a.active++
+		if a.active > a.maxActive {
+			a.maxActive = a.active
+		}
+		a.mu.Unlock()
+
+		time.Sleep(a.delay)
+
+		a.mu.Lock()
+		a.active--
+		status := a.status
+		if a.validKey != "" && a.validKey != r.Header.Get(config.HeaderKey) {
+			status = http.StatusUnauthorized
+		}
+		if a.maxBodySize > 0 && len(body) > a.maxBodySize {
+			status = http.StatusRequestEntityTooLarge
+		}
+		a.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(a.server.Close)
+
+	return a
+}
+
+func (a *acceptor) setStatus(status int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.status = status
+}
+
+func (a *acceptor) setValidKey(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.validKey = key
+}
+
+func (a *acceptor) setMaxBodySize(size int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.maxBodySize = size
+}
+
+// maxConcurrency returns the maximum number of requests handled at once
+func (a *acceptor) maxConcurrency() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.maxActive
+}
+
+// lastRequest returns the headers and the bundle of the last request
+func (a *acceptor) lastRequest() (http.Header, []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.lastHeader, a.lastBody
+}
+
+// probeRequests returns the number of empty bundles received, which are sent by probes
+func (a *acceptor) probeRequests() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.probes
+}
+
+func (a *acceptor) requests() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]string(nil), a.agentKeys...)
+}
+
+func newTestExporter(t *testing.T, cfg *config.Config) *instanaExporter {
+	exporter, err := newInstanaExporter(cfg, componenttest.NewNopExporterCreateSettings())
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	if err := exporter.start(context.Background(), componenttest.NewNopHost()); err != nil {
+		t.Fatalf("failed to start exporter: %v", err)
+	}
+
+	return exporter
+}
+
+// newTestTraces creates one resource with one span for each of the given namespaces
+func newTestTraces(namespaces ...string) ptrace.Traces {
+	traces := ptrace.NewTraces()
+
+	for _, namespace := range namespaces {
+		resourceSpans := traces.ResourceSpans().AppendEmpty()
+		attrs := generateAttrs()
+		attrs.InsertString("k8s.namespace.name", namespace)
+		attrs.CopyTo(resourceSpans.Resource().Attributes())
+
+		span := resourceSpans.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		setupSpan(&span, SpanOptions{})
+	}
+
+	return traces
+}
+
+func TestRouting(t *testing.T) {
+	defaultAcceptor := newAcceptor(t, http.StatusOK)
+	teamAcceptor := newAcceptor(t, http.StatusOK)
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = defaultAcceptor.server.URL
+	cfg.AgentKey = "default-key"
+	cfg.Routing = config.RoutingConfig{
+		Destinations: map[string]config.Destination{
+			"team-a": {Endpoint: teamAcceptor.server.URL, AgentKey: "team-a-key"},
+		},
+		Rules: []config.RoutingRule{
+			{Attributes: map[string]string{"k8s.namespace.name": "team-a"}, Destination: "team-a"},
+		},
+	}
+
+	exporter := newTestExporter(t, cfg)
+
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("team-a", "team-b", "team-a")); err != nil {
+		t.Fatalf("expected no error but received %v", err)
+	}
+
+	if keys := defaultAcceptor.requests(); len(keys) != 1 || keys[0] != "default-key" {
+		t.Errorf("expected one request with the default agent key but received %v", keys)
+	}
+
+	if keys := teamAcceptor.requests(); len(keys) != 1 || keys[0] != "team-a-key" {
+		t.Errorf("expected one request with the team-a agent key but received %v", keys)
+	}
+}
+
+func TestRoutingIsolatesFailingDestinations(t *testing.T) {
+	defaultAcceptor := newAcceptor(t, http.StatusOK)
+	teamAcceptor := newAcceptor(t, http.StatusUnauthorized)
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = defaultAcceptor.server.URL
+	cfg.AgentKey = "default-key"
+	cfg.Routing = config.RoutingConfig{
+		Destinations: map[string]config.Destination{
+			"team-a": {Endpoint: teamAcceptor.server.URL, AgentKey: "team-a-key"},
+		},
+		Rules: []config.RoutingRule{
+			{Attributes: map[string]string{"k8s.namespace.name": "team-a"}, Destination: "team-a"},
+		},
+	}
+
+	exporter := newTestExporter(t, cfg)
+
+	err := exporter.pushConvertedTraces(context.Background(), newTestTraces("team-a", "team-b"))
+	if err == nil {
+		t.Fatal("expected the rejected request to fail the export")
+	}
+
+	if !consumererror.IsPermanent(err) {
+		t.Errorf("expected a rejected request to be a permanent error but received %v", err)
+	}
+
+	if keys := defaultAcceptor.requests(); len(keys) != 1 {
+		t.Errorf("expected the default destination to receive its spans but received %v", keys)
+	}
+}
+
+func TestRoutingRetriesOnlyFailedDestinations(t *testing.T) {
+	defaultAcceptor := newAcceptor(t, http.StatusOK)
+	rejectingAcceptor := newAcceptor(t, http.StatusBadRequest)
+	unavailableAcceptor := newAcceptor(t, http.StatusServiceUnavailable)
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = defaultAcceptor.server.URL
+	cfg.AgentKey = "default-key"
+	cfg.Routing = config.RoutingConfig{
+		Destinations: map[string]config.Destination{
+			"team-a": {Endpoint: rejectingAcceptor.server.URL, AgentKey: "team-a-key"},
+			"team-b": {Endpoint: unavailableAcceptor.server.URL, AgentKey: "team-b-key"},
+		},
+		Rules: []config.RoutingRule{
+			{Attributes: map[string]string{"k8s.namespace.name": "team-a"}, Destination: "team-a"},
+			{Attributes: map[string]string{"k8s.namespace.name": "team-b"}, Destination: "team-b"},
+		},
+	}
+
+	exporter := newTestExporter(t, cfg)
+
+	err := exporter.pushConvertedTraces(context.Background(), newTestTraces("team-a", "team-b", "default", "team-b"))
+	if err == nil {
+		t.Fatal("expected the failed destinations to fail the export")
+	}
+
+	if consumererror.IsPermanent(err) {
+		t.Errorf("expected the unavailable destination to make the error retryable but received %v", err)
+	}
+
+	var tracesErr consumererror.Traces
+	if !errors.As(err, &tracesErr) {
+		t.Fatalf("expected the error to carry the traces to retry but received %v", err)
+	}
+
+	failed := tracesErr.GetTraces()
+	if failed.ResourceSpans().Len() != 2 {
+		t.Fatalf("expected only the resources of the unavailable destination to be retried but received %d", failed.ResourceSpans().Len())
+	}
+
+	for i := 0; i < failed.ResourceSpans().Len(); i++ {
+		namespace, _ := failed.ResourceSpans().At(i).Resource().Attributes().Get("k8s.namespace.name")
+		if namespace.AsString() != "team-b" {
+			t.Errorf("expected only team-b resources to be retried but received %v", namespace.AsString())
+		}
+	}
+
+	for name, a := range map[string]*acceptor{"default": defaultAcceptor, "team-a": rejectingAcceptor, "team-b": unavailableAcceptor} {
+		if keys := a.requests(); len(keys) != 1 {
+			t.Errorf("expected one request to destination %v but received %d", name, len(keys))
+		}
+	}
+}
+
+func TestFailover(t *testing.T) {
+	primary := newAcceptor(t, http.StatusServiceUnavailable)
+	secondary := newAcceptor(t, http.StatusOK)
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = primary.server.URL
+	cfg.FailoverEndpoints = []string{secondary.server.URL}
+	cfg.AgentKey = "key"
+	cfg.Failover.ProbeInterval = 50 * time.Millisecond
+
+	exporter := newTestExporter(t, cfg)
+
+	for i := 0; i < 2; i++ {
+		if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); err != nil {
+			t.Fatalf("expected the secondary endpoint to accept the spans but received %v", err)
+		}
+
+		// the primary endpoint is probed in the background meanwhile, but stays unavailable
+		time.Sleep(2 * cfg.Failover.ProbeInterval)
+	}
+
+	if requests := len(primary.requests()) - primary.probeRequests(); requests != 1 {
+		t.Errorf("expected the failed primary endpoint to receive no traffic until a probe succeeded but it received %d requests", requests)
+	}
+
+	if primary.probeRequests() == 0 {
+		t.Error("expected the failed primary endpoint to be probed in the background")
+	}
+
+	if requests := len(secondary.requests()); requests != 2 {
+		t.Errorf("expected the secondary endpoint to receive 2 requests but received %d", requests)
+	}
+
+	primary.setStatus(http.StatusOK)
+	deadline := time.Now().Add(time.Second)
+	for len(exporter.destinations[0].endpoints.Unhealthy()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if active := exporter.destinations[0].endpoints.Active(); active != secondary.server.URL {
+		t.Errorf("expected a successful probe not to switch the active endpoint but the active one is %v", active)
+	}
+
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); err != nil {
+		t.Fatalf("expected no error but received %v", err)
+	}
+
+	if requests := len(primary.requests()) - primary.probeRequests(); requests != 2 {
+		t.Errorf("expected the recovered primary endpoint to receive the spans but it received %d requests", requests)
+	}
+
+	if active := exporter.destinations[0].endpoints.Active(); active != primary.server.URL {
+		t.Errorf("expected to switch back to the primary endpoint but the active one is %v", active)
+	}
+
+	if err := exporter.shutdown(context.Background()); err != nil {
+		t.Errorf("expected no error on shutdown but received %v", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	unavailable := newAcceptor(t, http.StatusServiceUnavailable)
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = unavailable.server.URL
+	cfg.AgentKey = "key"
+	cfg.CircuitBreaker.FailureThreshold = 2
+	cfg.CircuitBreaker.OpenTimeout = 50 * time.Millisecond
+
+	exporter := newTestExporter(t, cfg)
+
+	for i := 0; i < 3; i++ {
+		err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default"))
+		if err == nil || consumererror.IsPermanent(err) {
+			t.Fatalf("expected a retryable error but received %v", err)
+		}
+	}
+
+	if requests := len(unavailable.requests()); requests != 2 {
+		t.Errorf("expected the open circuit breaker to fail fast after 2 requests but %d were sent", requests)
+	}
+
+	unavailable.setStatus(http.StatusOK)
+	time.Sleep(cfg.CircuitBreaker.OpenTimeout)
+
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); err != nil {
+		t.Fatalf("expected the probe request to succeed but received %v", err)
+	}
+
+	if state := exporter.destinations[0].breaker.State(); state != breaker.StateClosed {
+		t.Errorf("expected the circuit breaker to close after a successful probe but it is %v", state)
+	}
+}
+
+func TestAgentKeyRotation(t *testing.T) {
+	a := newAcceptor(t, http.StatusOK)
+	a.setValidKey("first-key")
+
+	keyFile := filepath.Join(t.TempDir(), "agent-key")
+	if err := os.WriteFile(keyFile, []byte("first-key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKeyFile = keyFile
+	cfg.AgentKeyReloadInterval = time.Hour
+
+	exporter := newTestExporter(t, cfg)
+
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); err != nil {
+		t.Fatalf("expected no error but received %v", err)
+	}
+
+	// rotate the key before the periodic reload notices
+	if err := os.WriteFile(keyFile, []byte("rotated-second-key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	a.setValidKey("rotated-second-key")
+
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); err != nil {
+		t.Fatalf("expected the rejected request to be retried with the rotated key but received %v", err)
+	}
+
+	expected := []string{"first-key", "first-key", "rotated-second-key"}
+	if keys := a.requests(); len(keys) != len(expected) || keys[1] != expected[1] || keys[2] != expected[2] {
+		t.Errorf("expected requests with the agent keys %v but received %v", expected, keys)
+	}
+
+	if err := exporter.shutdown(context.Background()); err != nil {
+		t.Errorf("expected no error on shutdown but received %v", err)
+	}
+}
+
+func TestAgentKeyRotatedDuringRequest(t *testing.T) {
+	a := newAcceptor(t, http.StatusOK)
+	a.setValidKey("first-key")
+
+	keyFile := filepath.Join(t.TempDir(), "agent-key")
+	if err := os.WriteFile(keyFile, []byte("first-key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKeyFile = keyFile
+	cfg.AgentKeyReloadInterval = time.Hour
+
+	exporter := newTestExporter(t, cfg)
+	a.delay = 100 * time.Millisecond
+
+	done := make(chan error)
+	go func() {
+		done <- exporter.pushConvertedTraces(context.Background(), newTestTraces("default"))
+	}()
+
+	for len(a.requests()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the periodic reload picks up the rotated key while the request with the old key is in flight
+	if err := os.WriteFile(keyFile, []byte("rotated-second-key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := exporter.destinations[0].agentKey.Reload(); !changed || err != nil {
+		t.Fatalf("expected the key to be reloaded but received %v, %v", changed, err)
+	}
+	a.setValidKey("rotated-second-key")
+
+	if err := <-done; err != nil {
+		t.Fatalf("expected the rejected request to be retried with the key loaded meanwhile but received %v", err)
+	}
+
+	expected := []string{"first-key", "rotated-second-key"}
+	if keys := a.requests(); len(keys) != len(expected) || keys[0] != expected[0] || keys[1] != expected[1] {
+		t.Errorf("expected requests with the agent keys %v but received %v", expected, keys)
+	}
+
+	if err := exporter.shutdown(context.Background()); err != nil {
+		t.Errorf("expected no error on shutdown but received %v", err)
+	}
+}
+
+func TestClockSkewCorrection(t *testing.T) {
+	a := newAcceptor(t, http.StatusOK)
+	a.clockOffset = time.Hour
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "key"
+	cfg.ClockSkew.Correct = true
+
+	exporter := newTestExporter(t, cfg)
+
+	for i := 0; i < 2; i++ {
+		if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); err != nil {
+			t.Fatalf("expected no error but received %v", err)
+		}
+	}
+
+	header, body := a.lastRequest()
+
+	sendTime, err := strconv.ParseInt(header.Get(config.HeaderTime), 10, 64)
+	if err != nil {
+		t.Fatalf("expected the send time in milliseconds but received %q", header.Get(config.HeaderTime))
+	}
+
+	skew := time.Duration(sendTime-time.Now().UnixMilli()) * time.Millisecond
+	if skew < 59*time.Minute || skew > 61*time.Minute {
+		t.Errorf("expected the send time to be corrected by an hour but it is off by %v", skew)
+	}
+
+	validateBundle(body, t, func(sp model.Span, t *testing.T) {
+		skew := time.Duration(int64(sp.Timestamp)-time.Now().UnixMilli()) * time.Millisecond
+		if skew < 59*time.Minute || skew > 61*time.Minute {
+			t.Errorf("expected the span timestamp to be corrected by an hour but it is off by %v", skew)
+		}
+	})
+}
+
+func TestSendTimePerRequest(t *testing.T) {
+	primary := newAcceptor(t, http.StatusServiceUnavailable)
+	primary.delay = 100 * time.Millisecond
+	secondary := newAcceptor(t, http.StatusOK)
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = primary.server.URL
+	cfg.FailoverEndpoints = []string{secondary.server.URL}
+	cfg.AgentKey = "key"
+
+	exporter := newTestExporter(t, cfg)
+
+	start := time.Now()
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); err != nil {
+		t.Fatalf("expected the secondary endpoint to accept the spans but received %v", err)
+	}
+
+	header, _ := secondary.lastRequest()
+
+	sendTime, err := strconv.ParseInt(header.Get(config.HeaderTime), 10, 64)
+	if err != nil {
+		t.Fatalf("expected the send time in milliseconds but received %q", header.Get(config.HeaderTime))
+	}
+
+	if sendTime < start.Add(primary.delay).UnixMilli() {
+		t.Errorf("expected the send time of the failover request to be taken after the primary endpoint failed but it is %v early",
+			time.Duration(start.Add(primary.delay).UnixMilli()-sendTime)*time.Millisecond)
+	}
+
+	if err := exporter.shutdown(context.Background()); err != nil {
+		t.Errorf("expected no error on shutdown but received %v", err)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	a := newAcceptor(t, http.StatusBadRequest)
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "secret-key"
+	cfg.DeadLetter.Directory = t.TempDir()
+
+	exporter := newTestExporter(t, cfg)
+
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); !consumererror.IsPermanent(err) {
+		t.Fatalf("expected a permanent error but received %v", err)
+	}
+
+	if err := exporter.shutdown(context.Background()); err != nil {
+		t.Fatalf("expected no error on shutdown but received %v", err)
+	}
+
+	files, err := filesink.Files(cfg.DeadLetter.Directory, deadletter.FilePrefix)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one dead-letter file but received %v (%v)", files, err)
+	}
+
+	records, err := deadletter.ReadFile(files[0])
+	if err != nil || len(records) != 1 {
+		t.Fatalf("expected one dead-letter record but received %v (%v)", records, err)
+	}
+
+	record := records[0]
+	if record.StatusCode != http.StatusBadRequest || record.Destination != config.DefaultDestinationName {
+		t.Errorf("expected the record of the rejected request but received %+v", record)
+	}
+
+	if _, ex := record.Headers[config.HeaderKey]; ex {
+		t.Error("expected the agent key not to be stored")
+	}
+
+	if record.Headers[config.HeaderHost] != "myhost1" {
+		t.Errorf("expected the host header to be stored but received %v", record.Headers)
+	}
+
+	validateBundle(record.Bundle, t, validateInstanaSpanBasics)
+}
+
+func TestSplitTooLargeBundle(t *testing.T) {
+	a := newAcceptor(t, http.StatusOK)
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "key"
+	cfg.DeadLetter.Directory = t.TempDir()
+
+	exporter := newTestExporter(t, cfg)
+
+	// a bundle of a single span fits, larger bundles are rejected
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); err != nil {
+		t.Fatalf("expected no error but received %v", err)
+	}
+	_, body := a.lastRequest()
+	a.setMaxBodySize(len(body))
+
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default", "default", "default", "default")); err != nil {
+		t.Fatalf("expected the split bundles to be accepted but received %v", err)
+	}
+
+	// 1 request of the single span, 3 rejected requests of 4 and 2 spans and 4 accepted requests of 1 span
+	if requests := len(a.requests()); requests != 8 {
+		t.Errorf("expected the rejected bundles to be split down to single spans but received %d requests", requests)
+	}
+
+	// a single span that is too large cannot be split any further
+	a.setMaxBodySize(1)
+
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default", "default")); !consumererror.IsPermanent(err) {
+		t.Fatalf("expected a permanent error but received %v", err)
+	}
+
+	if err := exporter.shutdown(context.Background()); err != nil {
+		t.Fatalf("expected no error on shutdown but received %v", err)
+	}
+
+	files, err := filesink.Files(cfg.DeadLetter.Directory, deadletter.FilePrefix)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one dead-letter file but received %v (%v)", files, err)
+	}
+
+	records, err := deadletter.ReadFile(files[0])
+	if err != nil || len(records) != 2 {
+		t.Fatalf("expected a dead-letter record of each span but received %v (%v)", records, err)
+	}
+
+	for _, record := range records {
+		if record.StatusCode != http.StatusRequestEntityTooLarge || record.Spans != 1 {
+			t.Errorf("expected the record of a single rejected span but received %+v", record)
+		}
+	}
+}
+
+func TestMaxInFlightBytes(t *testing.T) {
+	a := newAcceptor(t, http.StatusOK)
+	a.delay = 20 * time.Millisecond
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "key"
+	// every bundle exceeds the bound, so that only one is in flight at a time
+	cfg.MaxInFlightBytes = 1
+
+	exporter := newTestExporter(t, cfg)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = exporter.pushConvertedTraces(context.Background(), newTestTraces("default"))
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Errorf("expected no error but received %v", err)
+		}
+	}
+
+	if requests := len(a.requests()); requests != len(errs) {
+		t.Errorf("expected %d requests but received %d", len(errs), requests)
+	}
+
+	if concurrency := a.maxConcurrency(); concurrency != 1 {
+		t.Errorf("expected the bound to keep one request in flight but %d were", concurrency)
+	}
+}
+
+func TestMaxInFlightBytesGivesUpWithContext(t *testing.T) {
+	a := newAcceptor(t, http.StatusOK)
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "key"
+	cfg.MaxInFlightBytes = 1
+
+	exporter := newTestExporter(t, cfg)
+
+	if err := exporter.inFlight.Acquire(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := exporter.pushConvertedTraces(ctx, newTestTraces("default"))
+	if err == nil || consumererror.IsPermanent(err) {
+		t.Errorf("expected a retryable error while the bound is exceeded but received %v", err)
+	}
+
+	if requests := len(a.requests()); requests != 0 {
+		t.Errorf("expected no request while the bound is exceeded but %d were sent", requests)
+	}
+}
+
+// newObservedTestExporter creates a started exporter whose logs are recorded
+func newObservedTestExporter(t *testing.T, cfg *config.Config) (*instanaExporter, *observer.ObservedLogs) {
+	return newObservedTestExporterAtLevel(t, cfg, zap.InfoLevel)
+}
+
+// newObservedTestExporterAtLevel creates a started exporter whose logs at or above the level are recorded
+func newObservedTestExporterAtLevel(t *testing.T, cfg *config.Config, level zapcore.Level) (*instanaExporter, *observer.ObservedLogs) {
+	core, logs := observer.New(level)
+	settings := componenttest.NewNopExporterCreateSettings()
+	settings.Logger = zap.New(core)
+
+	exporter, err := newInstanaExporter(cfg, settings)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	if err := exporter.start(context.Background(), componenttest.NewNopHost()); err != nil {
+		t.Fatalf("failed to start exporter: %v", err)
+	}
+
+	return exporter, logs
+}
+
+// waitForRequests waits until the acceptor received the given number of requests
+func waitForRequests(t *testing.T, a *acceptor, requests int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for len(a.requests()) < requests {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d requests but received %d", requests, len(a.requests()))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func shutdownReport(t *testing.T, logs *observer.ObservedLogs) map[string]interface{} {
+	entries := logs.FilterMessageSnippet("Shut down").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected one shutdown report but received %v", entries)
+	}
+
+	return entries[0].ContextMap()
+}
+
+func TestShutdownDrainsInFlightPushes(t *testing.T) {
+	a := newAcceptor(t, http.StatusOK)
+	a.delay = 100 * time.Millisecond
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "key"
+
+	exporter, logs := newObservedTestExporter(t, cfg)
+
+	pushed := make(chan error)
+	go func() {
+		pushed <- exporter.pushConvertedTraces(context.Background(), newTestTraces("default"))
+	}()
+	waitForRequests(t, a, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := exporter.shutdown(ctx); err != nil {
+		t.Fatalf("expected no error on shutdown but received %v", err)
+	}
+
+	if err := <-pushed; err != nil {
+		t.Errorf("expected the push in flight to complete but received %v", err)
+	}
+
+	report := shutdownReport(t, logs)
+	if report["flushed_spans"] != int64(1) || report["abandoned_spans"] != int64(0) {
+		t.Errorf("expected one flushed span but received %v", report)
+	}
+
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); err != errShuttingDown {
+		t.Errorf("expected traces pushed after the shutdown to be rejected but received %v", err)
+	}
+}
+
+func TestShutdownAbandonsPushesAfterDeadline(t *testing.T) {
+	a := newAcceptor(t, http.StatusOK)
+	a.delay = time.Second
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "key"
+
+	exporter, logs := newObservedTestExporter(t, cfg)
+
+	pushed := make(chan error)
+	go func() {
+		pushed <- exporter.pushConvertedTraces(context.Background(), newTestTraces("default"))
+	}()
+	waitForRequests(t, a, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := exporter.shutdown(ctx); err != nil {
+		t.Fatalf("expected no error on shutdown but received %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the shutdown to end at its deadline but it took %v", elapsed)
+	}
+
+	if err := <-pushed; err == nil {
+		t.Error("expected the aborted push to fail")
+	}
+
+	report := shutdownReport(t, logs)
+	if report["flushed_spans"] != int64(0) || report["abandoned_spans"] != int64(1) {
+		t.Errorf("expected one abandoned span but received %v", report)
+	}
+}
+
+func TestShutdownWithoutDeadlineEndsItsWait(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	d := newDrainer()
+	d.beginShutdown(context.Background())
+	d.stop(context.Background())
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if goroutines := runtime.NumGoroutine(); goroutines > before {
+		t.Errorf("expected the wait for the shutdown context to end with the shutdown but %d goroutines are left over", goroutines-before)
+	}
+
+	if d.aborted() {
+		t.Error("expected a completed shutdown not to abort")
+	}
+}
+
+func TestShutdownDrainsQueue(t *testing.T) {
+	a := newAcceptor(t, http.StatusOK)
+	a.delay = 20 * time.Millisecond
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "key"
+	cfg.QueueSettings.Enabled = true
+	cfg.QueueSettings.NumConsumers = 1
+
+	exporter, err := NewFactory().CreateTracesExporter(context.Background(), componenttest.NewNopExporterCreateSettings(), cfg)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	if err := exporter.Start(context.Background(), componenttest.NewNopHost()); err != nil {
+		t.Fatalf("failed to start exporter: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := exporter.ConsumeTraces(context.Background(), newTestTraces("default")); err != nil {
+			t.Fatalf("expected the traces to be queued but received %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := exporter.Shutdown(ctx); err != nil {
+		t.Fatalf("expected no error on shutdown but received %v", err)
+	}
+
+	if requests := len(a.requests()); requests != 3 {
+		t.Errorf("expected the queued traces to be sent on shutdown but received %d requests", requests)
+	}
+}
+
+func TestRetryOnFailure(t *testing.T) {
+	a := newAcceptor(t, http.StatusServiceUnavailable)
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "key"
+	cfg.RetrySettings.Enabled = true
+	cfg.RetrySettings.InitialInterval = 10 * time.Millisecond
+
+	exporter, err := NewFactory().CreateTracesExporter(context.Background(), componenttest.NewNopExporterCreateSettings(), cfg)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	if err := exporter.Start(context.Background(), componenttest.NewNopHost()); err != nil {
+		t.Fatalf("failed to start exporter: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- exporter.ConsumeTraces(context.Background(), newTestTraces("default"))
+	}()
+
+	for len(a.requests()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	a.setStatus(http.StatusOK)
+
+	if err := <-done; err != nil {
+		t.Fatalf("expected the failed export to be retried but received %v", err)
+	}
+
+	if requests := len(a.requests()); requests != 2 {
+		t.Errorf("expected the export to be retried once but received %d requests", requests)
+	}
+
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Errorf("expected no error on shutdown but received %v", err)
+	}
+}
+
+func TestStartupCheck(t *testing.T) {
+	a := newAcceptor(t, http.StatusOK)
+	a.setValidKey("valid-key")
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "wrong-key"
+	cfg.HealthCheck.Startup = config.StartupCheckFail
+
+	exporter, err := newInstanaExporter(cfg, componenttest.NewNopExporterCreateSettings())
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	if err := exporter.start(context.Background(), componenttest.NewNopHost()); err == nil {
+		t.Error("expected the start to fail with a rejected agent key")
+	}
+
+	cfg.HealthCheck.Startup = config.StartupCheckWarn
+	_, logs := newObservedTestExporter(t, cfg)
+
+	if warnings := logs.FilterMessageSnippet("Startup check failed").Len(); warnings != 1 {
+		t.Errorf("expected a warning about the rejected agent key but received %d", warnings)
+	}
+
+	cfg.AgentKey = "valid-key"
+	cfg.HealthCheck.Startup = config.StartupCheckFail
+	newTestExporter(t, cfg)
+}
+
+func TestHealthCheck(t *testing.T) {
+	a := newAcceptor(t, http.StatusOK)
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "key"
+	cfg.HealthCheck.Interval = 10 * time.Millisecond
+
+	exporter, logs := newObservedTestExporter(t, cfg)
+	waitForRequests(t, a, 1)
+
+	a.setStatus(http.StatusServiceUnavailable)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for logs.FilterMessage("Destination is unhealthy").Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the health check to report the unavailable destination")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := exporter.shutdown(context.Background()); err != nil {
+		t.Errorf("expected no error on shutdown but received %v", err)
+	}
+}
+
+func TestHealthCheckFeedsCircuitBreaker(t *testing.T) {
+	a := newAcceptor(t, http.StatusServiceUnavailable)
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "key"
+	cfg.HealthCheck.Interval = 10 * time.Millisecond
+	cfg.CircuitBreaker.FailureThreshold = 2
+
+	exporter := newTestExporter(t, cfg)
+	d := exporter.destinations[0]
+
+	waitForState := func(state breaker.State) {
+		deadline := time.Now().Add(5 * time.Second)
+		for d.breaker.State() != state {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected the probes to move the circuit breaker to %v but it is %v", state, d.breaker.State())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	waitForState(breaker.StateOpen)
+
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); err == nil {
+		t.Error("expected the open circuit breaker to fail the export")
+	}
+
+	a.setStatus(http.StatusOK)
+	waitForState(breaker.StateClosed)
+
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); err != nil {
+		t.Errorf("expected the export to succeed once a probe closed the breaker but received %v", err)
+	}
+
+	if err := exporter.shutdown(context.Background()); err != nil {
+		t.Errorf("expected no error on shutdown but received %v", err)
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	a := newAcceptor(t, http.StatusOK)
+	a.delay = time.Second
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "key"
+	cfg.HealthCheck.Startup = config.StartupCheckFail
+	cfg.HealthCheck.Timeout = 20 * time.Millisecond
+
+	exporter, err := newInstanaExporter(cfg, componenttest.NewNopExporterCreateSettings())
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	started := time.Now()
+	if err := exporter.start(context.Background(), componenttest.NewNopHost()); err == nil {
+		t.Error("expected the start to fail with a probe timing out")
+	}
+
+	if elapsed := time.Since(started); elapsed >= a.delay {
+		t.Errorf("expected the probe to be bounded by the health check timeout but it took %v", elapsed)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.DryRun.Directory = t.TempDir()
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected a dry run to need neither endpoint nor agent key but received %v", err)
+	}
+
+	cfg.AgentKey = "secret-key"
+	exporter := newTestExporter(t, cfg)
+
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); err != nil {
+		t.Fatalf("expected no error but received %v", err)
+	}
+
+	if err := exporter.shutdown(context.Background()); err != nil {
+		t.Fatalf("expected no error on shutdown but received %v", err)
+	}
+
+	files, err := filesink.Files(cfg.DryRun.Directory, dryRunFilePrefix)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one dry run file but received %v (%v)", files, err)
+	}
+
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var record dryRunRecord
+	if err := json.Unmarshal(content, &record); err != nil {
+		t.Fatalf("expected a JSON line but received %s", content)
+	}
+
+	if record.Headers[config.HeaderKey] != redactedAgentKey {
+		t.Errorf("expected the agent key to be redacted but received %q", record.Headers[config.HeaderKey])
+	}
+
+	if record.Headers[config.HeaderHost] != "myhost1" {
+		t.Errorf("expected the headers that would have been sent but received %v", record.Headers)
+	}
+
+	validateBundle(record.Bundle, t, validateInstanaSpanBasics)
+}
+
+func TestExportWithoutDebugLogging(t *testing.T) {
+	a := newAcceptor(t, http.StatusOK)
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "key"
+
+	exporter, logs := newObservedTestExporterAtLevel(t, cfg, zapcore.InfoLevel)
+
+	if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); err != nil {
+		t.Fatalf("expected no error but received %v", err)
+	}
+
+	if requests := len(a.requests()); requests != 1 {
+		t.Errorf("expected spans to be sent regardless of the log level but received %d requests", requests)
+	}
+
+	if dumps := logs.FilterMessage("Received traces").Len() + logs.FilterMessage("Sending bundle").Len(); dumps != 0 {
+		t.Errorf("expected no payload dumps without debug logging but received %d", dumps)
+	}
+}
+
+func TestPayloadLoggingIsSampled(t *testing.T) {
+	a := newAcceptor(t, http.StatusOK)
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.Endpoint = a.server.URL
+	cfg.AgentKey = "key"
+
+	exporter, logs := newObservedTestExporterAtLevel(t, cfg, zapcore.DebugLevel)
+
+	for i := 0; i < 3; i++ {
+		if err := exporter.pushConvertedTraces(context.Background(), newTestTraces("default")); err != nil {
+			t.Fatalf("expected no error but received %v", err)
+		}
+	}
+
+	if requests := len(a.requests()); requests != 3 {
+		t.Errorf("expected 3 requests but received %d", requests)
+	}
+
+	// all pushes happen within one sampling tick
+	for _, message := range []string{"Received traces", "Sending bundle"} {
+		if dumps := logs.FilterMessage(message).Len(); dumps != 1 {
+			t.Errorf("expected one sampled %q payload dump but received %d", message, dumps)
+		}
+	}
+}
@@ -4,9 +4,6 @@ import (
 	"context"
 	"time"
 
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
-
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/config/confighttp"
@@ -35,8 +32,8 @@ func NewFactory() component.ExporterFactory {
 // createDefaultConfig creates the default exporter configuration
 func createDefaultConfig() config.Exporter {
 	return &instanaConfig.Config{
-		ExporterSettings: config.NewExporterSettings(config.NewComponentID(typeStr)),
-		LogLevel:         zapcore.InfoLevel,
+		ExporterSettings:       config.NewExporterSettings(config.NewComponentID(typeStr)),
+		AgentKeyReloadInterval: 10 * time.Second,
 		HTTPClientSettings: confighttp.HTTPClientSettings{
 			Endpoint: "",
 			Timeout:  30 * time.Second,
@@ -44,54 +41,125 @@ func createDefaultConfig() config.Exporter {
 			// We almost read 0 bytes, so no need to tune ReadBufferSize.
 			WriteBufferSize: 512 * 1024,
 		},
+		QueueSettings: queueSettings(),
+		RetrySettings: retrySettings(),
+		OperationNaming: instanaConfig.OperationNamingConfig{
+			Rules: instanaConfig.DefaultOperationNameRules(),
+		},
+		ServiceName: instanaConfig.DefaultServiceNameConfig(),
+		Correlation: instanaConfig.DefaultCorrelationConfig(),
+		TraceContinuity: instanaConfig.TraceContinuityConfig{
+			ReuseInstanaTraceID: false,
+		},
+		SpanKind: instanaConfig.SpanKindConfig{
+			Unspecified: instanaConfig.InstanaKindIntermediate,
+		},
+		ErrorClassification: instanaConfig.DefaultErrorClassificationConfig(),
+		Unsampled: instanaConfig.UnsampledConfig{
+			Policy:            instanaConfig.UnsampledPolicySend,
+			PriorityAttribute: "sampling.priority",
+		},
+		Sampling: instanaConfig.SamplingConfig{
+			Percentage: 100,
+		},
+		RateLimit: instanaConfig.RateLimitConfig{
+			Burst: time.Second,
+		},
+		Batching: instanaConfig.BatchingConfig{
+			MaxDuration: 10 * time.Millisecond,
+		},
+		RootEntry: instanaConfig.RootEntryConfig{
+			Mode: instanaConfig.RootEntryModeOff,
+		},
+		InternalSpans: instanaConfig.InternalSpansConfig{
+			Mode: instanaConfig.InternalSpansModeKeep,
+		},
+		Failover: instanaConfig.FailoverConfig{
+			ProbeInterval: 30 * time.Second,
+		},
+		CircuitBreaker: instanaConfig.CircuitBreakerConfig{
+			FailureThreshold: 5,
+			OpenTimeout:      30 * time.Second,
+		},
+		ClockSkew: instanaConfig.ClockSkewConfig{
+			Threshold: 2 * time.Second,
+		},
+		DeadLetter: instanaConfig.DefaultFileSinkConfig(),
+		HealthCheck: instanaConfig.HealthCheckConfig{
+			Startup: instanaConfig.StartupCheckOff,
+			Timeout: 5 * time.Second,
+		},
+		DryRun: instanaConfig.DefaultFileSinkConfig(),
 	}
 }
 
+// defaultNumSenders is the number of concurrent senders consuming the sending queue, set explicitly so that it does
+// not change with the collector's default number of queue consumers
+const defaultNumSenders = 10
+
+// queueSettings returns the default queue settings with the queue disabled, so that traces are sent synchronously
+func queueSettings() exporterhelper.QueueSettings {
+	settings := exporterhelper.NewDefaultQueueSettings()
+	settings.Enabled = false
+	settings.NumConsumers = defaultNumSenders
+
+	return settings
+}
+
+// retrySettings returns the default retry settings with retries disabled, so that a failed push returns its error
+// to the caller right away instead of blocking the pipeline while backing off
+func retrySettings() exporterhelper.RetrySettings {
+	settings := exporterhelper.NewDefaultRetrySettings()
+	settings.Enabled = false
+
+	return settings
+}
+
 // createTracesExporter creates a trace exporter based on this configuration
 func createTracesExporter(ctx context.Context, set component.ExporterCreateSettings, config config.Exporter) (component.TracesExporter, error) {
 	cfg := config.(*instanaConfig.Config)
 
-	exporterLogger, err := createLogger(cfg)
-	if err != nil {
-		return nil, err
-	}
-
 	ctx, cancel := context.WithCancel(ctx)
 
-	instanaExporter, err := newInstanaExporter(exporterLogger, cfg, set)
+	instanaExporter, err := newInstanaExporter(cfg, set)
 	if err != nil {
 		cancel()
 		return nil, err
 	}
 
-	return exporterhelper.NewTracesExporterWithContext(
+	exporter, err := exporterhelper.NewTracesExporterWithContext(
 		ctx,
 		set,
 		config,
 		instanaExporter.pushConvertedTraces,
 		exporterhelper.WithCapabilities(consumer.Capabilities{MutatesData: false}),
 		exporterhelper.WithStart(instanaExporter.start),
-		// Disable Timeout/RetryOnFailure and SendingQueue
+		// Disable Timeout, the HTTP client has its own timeout
 		exporterhelper.WithTimeout(exporterhelper.TimeoutSettings{Timeout: 0}),
-		exporterhelper.WithRetry(exporterhelper.RetrySettings{Enabled: false}),
-		exporterhelper.WithQueue(exporterhelper.QueueSettings{Enabled: false}),
-		exporterhelper.WithShutdown(func(context.Context) error {
+		exporterhelper.WithRetry(cfg.RetrySettings),
+		exporterhelper.WithQueue(cfg.QueueSettings),
+		exporterhelper.WithShutdown(func(ctx context.Context) error {
 			cancel()
-			return nil
+			return instanaExporter.shutdown(ctx)
 		}),
 	)
-}
-
-// createLogger creates a logger for logging trace and errors
-func createLogger(cfg *instanaConfig.Config) (*zap.Logger, error) {
-	// We take development config as the base since it matches the purpose
-	// of logging exporter being used for debugging reasons (so e.g. console encoder)
-	conf := zap.NewDevelopmentConfig()
-	conf.Level = zap.NewAtomicLevelAt(cfg.LogLevel)
-
-	logginglogger, err := conf.Build()
 	if err != nil {
+		cancel()
 		return nil, err
 	}
-	return logginglogger, nil
+
+	return &tracesExporter{TracesExporter: exporter, instanaExporter: instanaExporter}, nil
+}
+
+// tracesExporter bounds the whole shutdown by its context. The exporter helper drains the sending queue before it
+// calls the exporter's shutdown, so the deadline must be armed before.
+type tracesExporter struct {
+	component.TracesExporter
+	instanaExporter *instanaExporter
+}
+
+func (e *tracesExporter) Shutdown(ctx context.Context) error {
+	e.instanaExporter.beginShutdown(ctx)
+
+	return e.TracesExporter.Shutdown(ctx)
 }
@@ -0,0 +1,98 @@
+package instanaexporter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+)
+
+// failoverProber probes the unhealthy endpoints of the destinations in the background. Requests skip an endpoint
+// that failed until a probe found it available again, so that live traffic is never retried against it.
+type failoverProber struct {
+	exporter *instanaExporter
+	interval time.Duration
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+func newFailoverProber(exporter *instanaExporter, interval time.Duration) *failoverProber {
+	return &failoverProber{
+		exporter: exporter,
+		interval: interval,
+	}
+}
+
+// start probes the unhealthy endpoints in the configured interval, if any
+func (p *failoverProber) start() {
+	if p.interval <= 0 {
+		return
+	}
+
+	p.stopCh = make(chan struct{})
+	p.doneCh = make(chan struct{})
+
+	go func() {
+		defer close(p.doneCh)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stopCh:
+				return
+			case <-ticker.C:
+				p.check()
+			}
+		}
+	}()
+}
+
+func (p *failoverProber) stop() {
+	if p.stopCh == nil {
+		return
+	}
+
+	close(p.stopCh)
+	<-p.doneCh
+	p.stopCh = nil
+}
+
+func (p *failoverProber) check() {
+	e := p.exporter
+
+	for _, d := range e.destinations {
+		for _, endpoint := range d.endpoints.Unhealthy() {
+			ctx, cancel := context.WithTimeout(context.Background(), e.config.HealthCheck.Timeout)
+			err := e.probeEndpoint(ctx, d, endpoint)
+			cancel()
+
+			// any response but a server error proves the endpoint available, e.g. a rejected agent key
+			var unavailable *unavailableError
+			if errors.As(err, &unavailable) {
+				e.logger.Debug("Instana endpoint still unavailable", zap.String("destination", d.name), zap.String("endpoint", endpoint), zap.Error(err))
+				continue
+			}
+
+			e.logger.Info("Instana endpoint available again", zap.String("destination", d.name), zap.String("endpoint", endpoint))
+			d.endpoints.Recovered(endpoint)
+			d.breaker.Success()
+		}
+	}
+}
+
+// probeEndpoint sends an empty bundle to one endpoint of a destination, outside of its failover order
+func (e *instanaExporter) probeEndpoint(ctx context.Context, d *destination, endpoint string) error {
+	bundle := model.Bundle{Spans: []model.Span{}}
+
+	req, err := bundle.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return e.export(ctx, d.client, endpoint, d.requestHeaders(""), 0, req)
+}
@@ -0,0 +1,159 @@
+package instanaexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	instanaConfig "github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+)
+
+// probe sends an empty bundle to a destination, checking that an endpoint is reachable and accepts the agent key
+func (e *instanaExporter) probe(ctx context.Context, d *destination) error {
+	bundle := model.Bundle{Spans: []model.Span{}}
+
+	req, err := bundle.Marshal()
+	if err != nil {
+		return err
+	}
+
+	err = e.exportWithFailover(ctx, d, d.requestHeaders(""), 0, req)
+
+	var unavailable *unavailableError
+	switch {
+	case err == nil:
+		return nil
+	case isUnauthorized(err):
+		return fmt.Errorf("the agent key was rejected: %w", err)
+	case errors.As(err, &unavailable):
+		return fmt.Errorf("no endpoint is reachable: %w", err)
+	default:
+		return err
+	}
+}
+
+// probeDone records the result of a probe. The failover pool already took note of the endpoints that were
+// unavailable; an unavailable destination also counts as a failure of its circuit breaker, while any response
+// proves it reachable.
+func (e *instanaExporter) probeDone(d *destination, err error) {
+	e.recorder.DestinationHealth(d.name, err == nil)
+
+	var unavailable *unavailableError
+	if errors.As(err, &unavailable) {
+		d.breaker.Failure()
+	} else {
+		d.breaker.Success()
+	}
+}
+
+// checkStartup probes all destinations according to the startup mode
+func (e *instanaExporter) checkStartup(ctx context.Context) error {
+	mode := e.config.HealthCheck.Startup
+	if mode == instanaConfig.StartupCheckOff || e.dryRun != nil {
+		return nil
+	}
+
+	for _, d := range e.destinations {
+		probeCtx, cancel := context.WithTimeout(ctx, e.config.HealthCheck.Timeout)
+		err := e.probe(probeCtx, d)
+		cancel()
+
+		e.probeDone(d, err)
+
+		if err == nil {
+			e.logger.Info("Startup check passed", zap.String("destination", d.name))
+			continue
+		}
+
+		if mode == instanaConfig.StartupCheckFail {
+			return fmt.Errorf("startup check of destination %q failed: %w", d.name, err)
+		}
+
+		e.logger.Warn("Startup check failed, spans sent to this destination may be lost", zap.String("destination", d.name), zap.Error(err))
+	}
+
+	return nil
+}
+
+// healthChecker probes the destinations in the background and reports changes of their health
+type healthChecker struct {
+	exporter *instanaExporter
+	interval time.Duration
+	healthy  map[string]bool
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+func newHealthChecker(exporter *instanaExporter, interval time.Duration) *healthChecker {
+	return &healthChecker{
+		exporter: exporter,
+		interval: interval,
+		healthy:  make(map[string]bool),
+	}
+}
+
+// start probes the destinations in the configured interval, if any
+func (h *healthChecker) start() {
+	if h.interval <= 0 {
+		return
+	}
+
+	h.stopCh = make(chan struct{})
+	h.doneCh = make(chan struct{})
+
+	go func() {
+		defer close(h.doneCh)
+
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-h.stopCh:
+				return
+			case <-ticker.C:
+				h.check()
+			}
+		}
+	}()
+}
+
+func (h *healthChecker) stop() {
+	if h.stopCh == nil {
+		return
+	}
+
+	close(h.stopCh)
+	<-h.doneCh
+	h.stopCh = nil
+}
+
+func (h *healthChecker) check() {
+	e := h.exporter
+
+	for _, d := range e.destinations {
+		ctx, cancel := context.WithTimeout(context.Background(), e.config.HealthCheck.Timeout)
+		err := e.probe(ctx, d)
+		cancel()
+
+		e.probeDone(d, err)
+
+		healthy := err == nil
+
+		wasHealthy, checked := h.healthy[d.name]
+		h.healthy[d.name] = healthy
+		if checked && wasHealthy == healthy {
+			continue
+		}
+
+		if healthy {
+			e.logger.Info("Destination is healthy", zap.String("destination", d.name))
+		} else {
+			e.logger.Warn("Destination is unhealthy", zap.String("destination", d.name), zap.Error(err))
+		}
+	}
+}
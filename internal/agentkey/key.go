@@ -0,0 +1,144 @@
+package agentkey
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Key holds an Instana agent key, either configured statically or read from a file. A file-backed key is re-read
+// when the file changes, and the new key is swapped in atomically.
+type Key struct {
+	value atomic.Value
+	path  string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// Static creates a key that never changes
+func Static(key string) *Key {
+	k := &Key{}
+	k.value.Store(key)
+
+	return k
+}
+
+// FromFile creates a key read from the file at path
+func FromFile(path string) (*Key, error) {
+	k := &Key{path: path}
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// Get returns the current key
+func (k *Key) Get() string {
+	return k.value.Load().(string)
+}
+
+// Reload re-reads the key file if it changed since the last read. It reports whether the key changed; static keys
+// never change.
+func (k *Key) Reload() (bool, error) {
+	if k.path == "" {
+		return false, nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat agent key file: %w", err)
+	}
+
+	if info.ModTime().Equal(k.modTime) && info.Size() == k.size {
+		return false, nil
+	}
+
+	previous := k.Get()
+	if err := k.read(); err != nil {
+		return false, err
+	}
+
+	return k.Get() != previous, nil
+}
+
+// Watch reloads the key file in the given interval until Stop is called. onReload is called with the result of every
+// reload that changed the key or failed.
+func (k *Key) Watch(interval time.Duration, onReload func(changed bool, err error)) {
+	if k.path == "" {
+		return
+	}
+
+	k.stop = make(chan struct{})
+	k.done = make(chan struct{})
+
+	go func() {
+		defer close(k.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-k.stop:
+				return
+			case <-ticker.C:
+				if changed, err := k.Reload(); changed || err != nil {
+					onReload(changed, err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops watching the key file
+func (k *Key) Stop() {
+	if k.stop == nil {
+		return
+	}
+
+	close(k.stop)
+	<-k.done
+	k.stop = nil
+}
+
+func (k *Key) load() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.read()
+}
+
+// read reads the key file, the caller must hold the lock
+func (k *Key) read() error {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat agent key file: %w", err)
+	}
+
+	content, err := os.ReadFile(k.path)
+	if err != nil {
+		return fmt.Errorf("failed to read agent key file: %w", err)
+	}
+
+	key := string(bytes.TrimSpace(content))
+	if key == "" {
+		return fmt.Errorf("agent key file %s is empty", k.path)
+	}
+
+	k.value.Store(key)
+	k.modTime = info.ModTime()
+	k.size = info.Size()
+
+	return nil
+}
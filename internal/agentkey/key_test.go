@@ -0,0 +1,55 @@
+package agentkey
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyFromFileReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent-key")
+	require.NoError(t, os.WriteFile(path, []byte("first-key\n"), 0600))
+
+	key, err := FromFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "first-key", key.Get())
+
+	changed, err := key.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	require.NoError(t, os.WriteFile(path, []byte("second-key-rotated\n"), 0600))
+
+	changed, err = key.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "second-key-rotated", key.Get())
+}
+
+func TestKeyFromFileKeepsKeyOnFailedReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent-key")
+	require.NoError(t, os.WriteFile(path, []byte("first-key"), 0600))
+
+	key, err := FromFile(path)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(" \n"), 0600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+
+	_, err = key.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, "first-key", key.Get())
+}
+
+func TestStaticKeyNeverChanges(t *testing.T) {
+	key := Static("key")
+
+	changed, err := key.Reload()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, "key", key.Get())
+}
@@ -0,0 +1,163 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	// StateClosed lets all requests pass
+	StateClosed State = iota
+	// StateOpen rejects all requests until the open timeout elapsed
+	StateOpen
+	// StateHalfOpen lets a single probe request pass, whose result closes or reopens the breaker
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker opens after a number of consecutive failures, so that requests to an unavailable backend fail fast. A nil
+// Breaker lets all requests pass.
+type Breaker struct {
+	mu          sync.Mutex
+	state       State
+	failures    int
+	threshold   int
+	openTimeout time.Duration
+	openedAt    time.Time
+	probing     bool
+	onChange    func(State)
+	now         func() time.Time
+}
+
+// New creates a closed breaker opening after threshold consecutive failures. onChange is called with the new state
+// on every transition.
+func New(threshold int, openTimeout time.Duration, onChange func(State)) *Breaker {
+	return newBreaker(threshold, openTimeout, onChange, time.Now)
+}
+
+func newBreaker(threshold int, openTimeout time.Duration, onChange func(State), now func() time.Time) *Breaker {
+	if onChange == nil {
+		onChange = func(State) {}
+	}
+
+	return &Breaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		onChange:    onChange,
+		now:         now,
+	}
+}
+
+// Allow reports whether a request may be sent. Once the open timeout elapsed, the breaker half-opens and allows a
+// single probe request.
+func (b *Breaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.transition(StateHalfOpen)
+		b.probing = true
+		return true
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Rejecting reports whether the breaker is open and would reject a request, without probing
+func (b *Breaker) Rejecting() bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		return b.now().Sub(b.openedAt) < b.openTimeout
+	case StateHalfOpen:
+		return b.probing
+	default:
+		return false
+	}
+}
+
+// Success records a successful request and closes the breaker
+func (b *Breaker) Success() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	b.transition(StateClosed)
+}
+
+// Failure records a failed request. The breaker opens once the threshold is reached or when the probe failed.
+func (b *Breaker) Failure() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		b.transition(StateOpen)
+	}
+}
+
+// State returns the current state of the breaker
+func (b *Breaker) State() State {
+	if b == nil {
+		return StateClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+func (b *Breaker) transition(state State) {
+	if b.state == state {
+		return
+	}
+
+	b.state = state
+	b.onChange(state)
+}
@@ -0,0 +1,53 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	now := time.Unix(0, 0)
+	var states []State
+	b := newBreaker(3, time.Minute, func(s State) { states = append(states, s) }, func() time.Time { return now })
+
+	b.Failure()
+	b.Failure()
+	b.Success()
+	b.Failure()
+	b.Failure()
+	assert.True(t, b.Allow())
+
+	b.Failure()
+	assert.Equal(t, StateOpen, b.State())
+	assert.False(t, b.Allow())
+	assert.True(t, b.Rejecting())
+	assert.Equal(t, []State{StateOpen}, states)
+}
+
+func TestBreakerHalfOpensToProbe(t *testing.T) {
+	now := time.Unix(0, 0)
+	var states []State
+	b := newBreaker(1, time.Minute, func(s State) { states = append(states, s) }, func() time.Time { return now })
+
+	b.Failure()
+	now = now.Add(time.Minute)
+	assert.False(t, b.Rejecting())
+
+	assert.True(t, b.Allow())
+	assert.Equal(t, StateHalfOpen, b.State())
+	assert.False(t, b.Allow(), "only one probe may be in flight")
+
+	b.Failure()
+	assert.Equal(t, StateOpen, b.State())
+	assert.False(t, b.Allow())
+
+	now = now.Add(time.Minute)
+	assert.True(t, b.Allow())
+	b.Success()
+	assert.Equal(t, StateClosed, b.State())
+	assert.True(t, b.Allow())
+
+	assert.Equal(t, []State{StateOpen, StateHalfOpen, StateOpen, StateHalfOpen, StateClosed}, states)
+}
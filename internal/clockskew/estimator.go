@@ -0,0 +1,60 @@
+package clockskew
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// smoothing is the weight of a new sample in the estimated offset
+	smoothing = 0.2
+
+	// dateResolution is the resolution of the HTTP Date header, which truncates to full seconds
+	dateResolution = time.Second
+)
+
+// Estimator estimates the offset of the local clock to the clock of the Instana backend from the Date headers of its
+// responses. The offset is positive when the local clock is behind.
+type Estimator struct {
+	mu      sync.Mutex
+	offset  time.Duration
+	sampled bool
+}
+
+func NewEstimator() *Estimator {
+	return &Estimator{}
+}
+
+// Observe adds a sample from a response received at received for a request sent at sent. Responses without a valid
+// Date header are ignored.
+func (e *Estimator) Observe(sent time.Time, received time.Time, date string) {
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+
+	// the server time lies somewhere in the truncated second, compare its middle to the middle of the round trip
+	serverTime = serverTime.Add(dateResolution / 2)
+	localTime := sent.Add(received.Sub(sent) / 2)
+	sample := serverTime.Sub(localTime)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.sampled {
+		e.offset = sample
+		e.sampled = true
+		return
+	}
+
+	e.offset += time.Duration(smoothing * float64(sample-e.offset))
+}
+
+// Offset returns the estimated offset, and false if no response was observed yet
+func (e *Estimator) Offset() (time.Duration, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.offset, e.sampled
+}
@@ -0,0 +1,47 @@
+package clockskew
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimatorMeasuresOffset(t *testing.T) {
+	estimator := NewEstimator()
+
+	_, sampled := estimator.Offset()
+	assert.False(t, sampled)
+
+	sent := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	received := sent.Add(200 * time.Millisecond)
+	date := sent.Add(time.Minute).Format(http.TimeFormat)
+
+	estimator.Observe(sent, received, date)
+
+	offset, sampled := estimator.Offset()
+	assert.True(t, sampled)
+	assert.InDelta(t, time.Minute, offset, float64(time.Second))
+}
+
+func TestEstimatorSmoothesSamples(t *testing.T) {
+	estimator := NewEstimator()
+
+	sent := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	estimator.Observe(sent, sent, sent.Add(10*time.Second).Format(http.TimeFormat))
+	estimator.Observe(sent, sent, sent.Add(20*time.Second).Format(http.TimeFormat))
+
+	offset, _ := estimator.Offset()
+	assert.Greater(t, offset, 10*time.Second)
+	assert.Less(t, offset, 20*time.Second)
+}
+
+func TestEstimatorIgnoresInvalidDates(t *testing.T) {
+	estimator := NewEstimator()
+
+	estimator.Observe(time.Now(), time.Now(), "yesterday")
+
+	_, sampled := estimator.Offset()
+	assert.False(t, sampled)
+}
@@ -3,7 +3,9 @@ package converter
 import (
 	"fmt"
 
+	"github.com/ibm-observability/instanaexporter/config"
 	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"github.com/ibm-observability/instanaexporter/internal/metrics"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.uber.org/zap"
@@ -16,21 +18,21 @@ type ConvertAllConverter struct {
 	logger     *zap.Logger
 }
 
-func (c *ConvertAllConverter) AcceptsSpans(attributes pcommon.Map, spanSlice ptrace.SpanSlice) bool {
+func (c *ConvertAllConverter) AcceptsSpans(attributes pcommon.Map, scope pcommon.InstrumentationScope, spanSlice ptrace.SpanSlice) bool {
 	return true
 }
 
-func (c *ConvertAllConverter) ConvertSpans(attributes pcommon.Map, spanSlice ptrace.SpanSlice) model.Bundle {
+func (c *ConvertAllConverter) ConvertSpans(attributes pcommon.Map, scope pcommon.InstrumentationScope, spanSlice ptrace.SpanSlice) model.Bundle {
 	bundle := model.NewBundle()
 
 	for i := 0; i < len(c.converters); i++ {
-		if !c.converters[i].AcceptsSpans(attributes, spanSlice) {
-			c.logger.Debug(fmt.Sprintf("Converter %s didnt Accept", c.converters[i].Name()))
+		if !c.converters[i].AcceptsSpans(attributes, scope, spanSlice) {
+			c.logger.Debug(fmt.Sprintf("Converter %s didnt Accept scope %q", c.converters[i].Name(), scope.Name()))
 
 			continue
 		}
 
-		converterBundle := c.converters[i].ConvertSpans(attributes, spanSlice)
+		converterBundle := c.converters[i].ConvertSpans(attributes, scope, spanSlice)
 		if len(converterBundle.Spans) > 0 {
 			bundle.Spans = append(bundle.Spans, converterBundle.Spans...)
 		}
@@ -43,12 +45,16 @@ func (c *ConvertAllConverter) Name() string {
 	return "ConvertAllConverter"
 }
 
-func NewConvertAllConverter(logger *zap.Logger) Converter {
+func NewConvertAllConverter(logger *zap.Logger, recorder *metrics.Recorder, cfg *config.Config) (Converter, error) {
+	spanConverter, err := NewSpanConverter(logger, recorder, cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	return &ConvertAllConverter{
 		converters: []Converter{
-			&SpanConverter{logger: logger},
+			spanConverter,
 		},
 		logger: logger,
-	}
+	}, nil
 }
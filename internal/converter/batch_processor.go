@@ -0,0 +1,48 @@
+package converter
+
+import (
+	"github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"github.com/ibm-observability/instanaexporter/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// BatchProcessor processes the converted spans of a whole export batch, which allows decisions
+// across all resources and scopes a trace is spread over
+type BatchProcessor interface {
+	ProcessSpans(spans []model.Span) []model.Span
+	Name() string
+}
+
+// NewBatchProcessors creates the batch processors enabled in the configuration, in the order they are to be applied
+func NewBatchProcessors(logger *zap.Logger, recorder *metrics.Recorder, cfg *config.Config) ([]BatchProcessor, error) {
+	processors := make([]BatchProcessor, 0)
+
+	if len(cfg.Exclusions) > 0 {
+		exclusionProcessor, err := NewExclusionProcessor(logger, recorder, cfg.Exclusions)
+		if err != nil {
+			return nil, err
+		}
+
+		processors = append(processors, exclusionProcessor)
+	}
+
+	if cfg.RootEntry.Mode != config.RootEntryModeOff {
+		processors = append(processors, NewRootEntryProcessor(logger, cfg.RootEntry.Mode))
+	}
+
+	// fold before batching, as folded spans' children become siblings that can be aggregated
+	if cfg.InternalSpans.Mode != config.InternalSpansModeKeep {
+		processors = append(processors, NewInternalSpanFoldingProcessor(logger, cfg.InternalSpans.Mode))
+	}
+
+	if cfg.Batching.Enabled {
+		processors = append(processors, NewBatchingProcessor(logger, cfg.Batching.MaxDuration))
+	}
+
+	if cfg.TraceContinuity.ReuseInstanaTraceID {
+		processors = append(processors, &TraceContinuityProcessor{logger: logger})
+	}
+
+	return processors, nil
+}
@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"go.uber.org/zap"
+)
+
+var _ BatchProcessor = (*BatchingProcessor)(nil)
+
+// batchKey groups sibling spans that can be aggregated
+type batchKey struct {
+	traceId   string
+	parentId  string
+	service   string
+	operation string
+	kind      int
+}
+
+// BatchingProcessor collapses fast sibling spans with the same parent, operation and kind, such as
+// N+1 database queries, into a single Instana batch span, as Instana's native tracers do
+type BatchingProcessor struct {
+	logger      *zap.Logger
+	maxDuration time.Duration
+}
+
+func NewBatchingProcessor(logger *zap.Logger, maxDuration time.Duration) *BatchingProcessor {
+	return &BatchingProcessor{
+		logger:      logger,
+		maxDuration: maxDuration,
+	}
+}
+
+func (p *BatchingProcessor) ProcessSpans(spans []model.Span) []model.Span {
+	// spans with children cannot be aggregated without losing the children's parent
+	parents := make(map[spanKey]bool)
+	for _, span := range spans {
+		if span.ParentID != "" {
+			parents[spanKey{traceId: span.TraceID, spanId: span.ParentID}] = true
+		}
+	}
+
+	groups := make(map[batchKey][]int)
+	for i, span := range spans {
+		if span.ParentID == "" || parents[keyOf(span)] || time.Duration(span.Duration)*time.Millisecond >= p.maxDuration {
+			continue
+		}
+
+		key := batchKey{
+			traceId:   span.TraceID,
+			parentId:  span.ParentID,
+			service:   span.Data.ServiceName,
+			operation: span.Data.Operation,
+			kind:      span.Kind,
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	aggregated := make(map[int]bool)
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+
+		sort.SliceStable(group, func(i, j int) bool {
+			return spans[group[i]].Timestamp < spans[group[j]].Timestamp
+		})
+
+		// the batch span starts with the first span and lasts as long as all spans together
+		batchSpan := &spans[group[0]]
+		batchSpan.Batch = &model.BatchInfo{Size: 1}
+
+		for _, i := range group[1:] {
+			batchSpan.Batch.Size++
+			batchSpan.Duration += spans[i].Duration
+			batchSpan.Ec += spans[i].Ec
+
+			aggregated[i] = true
+		}
+	}
+
+	if len(aggregated) == 0 {
+		return spans
+	}
+
+	kept := spans[:0]
+	for i, span := range spans {
+		if !aggregated[i] {
+			kept = append(kept, span)
+		}
+	}
+
+	p.logger.Debug("Aggregated spans into batch spans", zap.Int("aggregated", len(aggregated)))
+
+	return kept
+}
+
+func (p *BatchingProcessor) Name() string {
+	return "BatchingProcessor"
+}
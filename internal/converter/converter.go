@@ -7,7 +7,7 @@ import (
 )
 
 type Converter interface {
-	AcceptsSpans(attributes pcommon.Map, spanSlice ptrace.SpanSlice) bool
-	ConvertSpans(attributes pcommon.Map, spanSlice ptrace.SpanSlice) model.Bundle
+	AcceptsSpans(attributes pcommon.Map, scope pcommon.InstrumentationScope, spanSlice ptrace.SpanSlice) bool
+	ConvertSpans(attributes pcommon.Map, scope pcommon.InstrumentationScope, spanSlice ptrace.SpanSlice) model.Bundle
 	Name() string
 }
@@ -0,0 +1,147 @@
+package converter
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const (
+	levelCorrelationType = "correlationType"
+	levelCorrelationID   = "correlationId"
+
+	// baggage members set by Instana website, mobile and synthetic monitoring
+	baggageCorrelationType = "instana-correlation-type"
+	baggageCorrelationID   = "instana-correlation-id"
+	baggageSynthetic       = "instana-synthetic"
+)
+
+// correlator fills the EUM and synthetic correlation fields of entry spans
+type correlator struct {
+	cfg config.CorrelationConfig
+}
+
+func (c *correlator) correlate(instanaSpan *model.Span, otelSpan ptrace.Span) {
+	if instanaSpan.Kind != model.INSTANA_KIND_ENTRY {
+		return
+	}
+
+	attributes := otelSpan.Attributes()
+	baggage := parseBaggage(attributeString(attributes, c.cfg.BaggageAttribute))
+	level := parseLevelHeader(attributeString(attributes, c.cfg.LevelHeaderAttribute))
+
+	instanaSpan.CorrelationType = firstNonEmpty(
+		attributeString(attributes, c.cfg.TypeAttribute),
+		baggage[baggageCorrelationType],
+		level[levelCorrelationType],
+	)
+
+	instanaSpan.CorrelationID = firstNonEmpty(
+		attributeString(attributes, c.cfg.IDAttribute),
+		baggage[baggageCorrelationID],
+		level[levelCorrelationID],
+	)
+
+	if instanaSpan.CorrelationID == "" && instanaSpan.CorrelationType != "" {
+		instanaSpan.CorrelationID = c.traceStateCorrelationID(otelSpan)
+	}
+
+	instanaSpan.Synthetic = isTruthy(attributeString(attributes, c.cfg.SyntheticAttribute)) ||
+		isTruthy(baggage[baggageSynthetic]) ||
+		isTruthy(attributeString(attributes, c.cfg.SyntheticHeaderAttribute))
+}
+
+// traceStateCorrelationID returns the Instana trace ID of the "in" tracestate member, which EUM
+// leaves on the calls it makes in W3C mode instead of sending an X-INSTANA-L header. Any Instana
+// traced caller sets this member, so it is only used once the correlation type was found elsewhere.
+func (c *correlator) traceStateCorrelationID(otelSpan ptrace.Span) string {
+	if !c.cfg.UseTraceState {
+		return ""
+	}
+
+	if ancestor := model.ParseInstanaAncestor(otelSpan.TraceState()); ancestor != nil {
+		return ancestor.TraceID
+	}
+
+	return ""
+}
+
+// attributeString returns the attribute value as string. Captured headers are string slices,
+// in which case the first element is used.
+func attributeString(attributes pcommon.Map, key string) string {
+	if key == "" {
+		return ""
+	}
+
+	value, ex := attributes.Get(key)
+	if !ex {
+		return ""
+	}
+
+	if value.Type() == pcommon.ValueTypeSlice {
+		if value.SliceVal().Len() == 0 {
+			return ""
+		}
+
+		return value.SliceVal().At(0).AsString()
+	}
+
+	return value.AsString()
+}
+
+// parseBaggage parses a W3C baggage header into its member values, member properties are ignored
+func parseBaggage(header string) map[string]string {
+	members := make(map[string]string)
+
+	for _, member := range strings.Split(header, ",") {
+		member, _, _ = strings.Cut(member, ";")
+
+		key, value, found := strings.Cut(member, "=")
+		if !found {
+			continue
+		}
+
+		if decoded, err := url.PathUnescape(strings.TrimSpace(value)); err == nil {
+			members[strings.TrimSpace(key)] = decoded
+		}
+	}
+
+	return members
+}
+
+// parseLevelHeader parses the correlation part of an X-INSTANA-L header, e.g. "1,correlationType=web;correlationId=1234"
+func parseLevelHeader(header string) map[string]string {
+	values := make(map[string]string)
+
+	_, correlation, found := strings.Cut(header, ",")
+	if !found {
+		return values
+	}
+
+	for _, part := range strings.Split(correlation, ";") {
+		key, value, found := strings.Cut(part, "=")
+		if found {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	return values
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func isTruthy(value string) bool {
+	return value == "1" || strings.EqualFold(value, "true")
+}
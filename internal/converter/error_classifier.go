@@ -0,0 +1,167 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	conventions "go.opentelemetry.io/collector/semconv/v1.8.0"
+)
+
+const (
+	exceptionEventName = "exception"
+
+	// values of the error tag, naming the source a span was classified as erroneous by
+	errorSourceHTTPStatus = "HTTP_STATUS_CODE"
+	errorSourceGRPCStatus = "GRPC_STATUS_CODE"
+	errorSourceException  = "EXCEPTION"
+	errorSourceAttribute  = "ERROR_ATTRIBUTE"
+)
+
+// errorClassifier determines the error count and detail of spans from their status, HTTP and gRPC
+// status codes, exception events and custom attributes
+type errorClassifier struct {
+	httpServerStatusCodes []config.StatusCodeRange
+	httpClientStatusCodes []config.StatusCodeRange
+	grpcServerStatusCodes []config.StatusCodeRange
+	grpcClientStatusCodes []config.StatusCodeRange
+	exceptionEvents       bool
+	attributes            []string
+}
+
+func newErrorClassifier(cfg config.ErrorClassificationConfig) (*errorClassifier, error) {
+	var err error
+	classifier := &errorClassifier{
+		exceptionEvents: cfg.ExceptionEvents,
+		attributes:      cfg.Attributes,
+	}
+
+	if classifier.httpServerStatusCodes, err = config.ParseStatusCodeRanges(cfg.HTTPServerStatusCodes); err != nil {
+		return nil, err
+	}
+
+	if classifier.httpClientStatusCodes, err = config.ParseStatusCodeRanges(cfg.HTTPClientStatusCodes); err != nil {
+		return nil, err
+	}
+
+	if classifier.grpcServerStatusCodes, err = config.ParseStatusCodeRanges(cfg.GRPCServerStatusCodes); err != nil {
+		return nil, err
+	}
+
+	if classifier.grpcClientStatusCodes, err = config.ParseStatusCodeRanges(cfg.GRPCClientStatusCodes); err != nil {
+		return nil, err
+	}
+
+	return classifier, nil
+}
+
+// classify sets the error count and the error tags of the span. The error count is the number of
+// recorded exceptions, or 1 if the span is erroneous for any other reason. The error detail is
+// taken from the most specific source: the last exception, the status message or the status code.
+func (c *errorClassifier) classify(instanaSpan *model.Span, otelSpan ptrace.Span) {
+	source := ""
+	detail := ""
+
+	if otelSpan.Status().Code() == ptrace.StatusCodeError {
+		source = otelSpan.Status().Code().String()
+		detail = otelSpan.Status().Message()
+	}
+
+	httpStatusCodes, grpcStatusCodes := c.statusCodeRanges(instanaSpan.Kind)
+
+	if code, ok := intAttribute(otelSpan.Attributes(), conventions.AttributeHTTPStatusCode); ok && inRanges(code, httpStatusCodes) {
+		source = firstNonEmpty(source, errorSourceHTTPStatus)
+		detail = firstNonEmpty(detail, fmt.Sprintf("HTTP status code %d", code))
+	}
+
+	if code, ok := intAttribute(otelSpan.Attributes(), conventions.AttributeRPCGRPCStatusCode); ok && inRanges(code, grpcStatusCodes) {
+		source = firstNonEmpty(source, errorSourceGRPCStatus)
+		detail = firstNonEmpty(detail, fmt.Sprintf("gRPC status code %d", code))
+	}
+
+	for _, attribute := range c.attributes {
+		if isTruthy(attributeString(otelSpan.Attributes(), attribute)) {
+			source = firstNonEmpty(source, errorSourceAttribute)
+			detail = firstNonEmpty(detail, attribute)
+		}
+	}
+
+	exceptions := 0
+	if c.exceptionEvents {
+		events := otelSpan.Events()
+		for i := 0; i < events.Len(); i++ {
+			if events.At(i).Name() != exceptionEventName {
+				continue
+			}
+
+			exceptions++
+			source = firstNonEmpty(source, errorSourceException)
+
+			if message := exceptionMessage(events.At(i).Attributes()); message != "" {
+				detail = message
+			}
+		}
+	}
+
+	if source == "" {
+		return
+	}
+
+	instanaSpan.Ec = exceptions
+	if instanaSpan.Ec == 0 {
+		instanaSpan.Ec = 1
+	}
+
+	instanaSpan.Data.Tags[model.INSTANA_DATA_ERROR] = source
+	instanaSpan.Data.Tags[model.INSTANA_DATA_ERROR_DETAIL] = firstNonEmpty(detail, source)
+}
+
+func (c *errorClassifier) statusCodeRanges(instanaKind int) (http []config.StatusCodeRange, grpc []config.StatusCodeRange) {
+	switch instanaKind {
+	case model.INSTANA_KIND_ENTRY:
+		return c.httpServerStatusCodes, c.grpcServerStatusCodes
+	case model.INSTANA_KIND_EXIT:
+		return c.httpClientStatusCodes, c.grpcClientStatusCodes
+	default:
+		return nil, nil
+	}
+}
+
+func exceptionMessage(attributes pcommon.Map) string {
+	exceptionType := attributeString(attributes, conventions.AttributeExceptionType)
+	message := attributeString(attributes, conventions.AttributeExceptionMessage)
+
+	if exceptionType != "" && message != "" {
+		return exceptionType + ": " + message
+	}
+
+	return firstNonEmpty(message, exceptionType)
+}
+
+func intAttribute(attributes pcommon.Map, key string) (int, bool) {
+	value, ex := attributes.Get(key)
+	if !ex {
+		return 0, false
+	}
+
+	if value.Type() == pcommon.ValueTypeInt {
+		return int(value.IntVal()), true
+	}
+
+	i, err := strconv.Atoi(value.AsString())
+
+	return i, err == nil
+}
+
+func inRanges(code int, ranges []config.StatusCodeRange) bool {
+	for _, r := range ranges {
+		if code >= r.Min && code <= r.Max {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,209 @@
+package converter
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"github.com/ibm-observability/instanaexporter/internal/metrics"
+	"go.uber.org/zap"
+)
+
+var _ BatchProcessor = (*ExclusionProcessor)(nil)
+
+type attributeMatcher struct {
+	key     string
+	value   string
+	pattern *regexp.Regexp
+}
+
+type exclusionRule struct {
+	cfg              config.ExclusionRule
+	operationPattern *regexp.Regexp
+	spanKinds        map[string]bool
+	attributes       []attributeMatcher
+}
+
+// spanKey identifies a span within a batch
+type spanKey struct {
+	traceId string
+	spanId  string
+}
+
+// ExclusionProcessor drops spans matching any of the configured exclusion rules and, if the rule
+// asks for it, their descendants within the same batch
+type ExclusionProcessor struct {
+	logger   *zap.Logger
+	recorder *metrics.Recorder
+	rules    []exclusionRule
+}
+
+func NewExclusionProcessor(logger *zap.Logger, recorder *metrics.Recorder, rules []config.ExclusionRule) (*ExclusionProcessor, error) {
+	processor := &ExclusionProcessor{
+		logger:   logger,
+		recorder: recorder,
+	}
+
+	for _, ruleCfg := range rules {
+		rule := exclusionRule{
+			cfg:       ruleCfg,
+			spanKinds: make(map[string]bool),
+		}
+
+		if ruleCfg.OperationPattern != "" {
+			pattern, err := regexp.Compile(ruleCfg.OperationPattern)
+			if err != nil {
+				return nil, err
+			}
+			rule.operationPattern = pattern
+		}
+
+		for _, kind := range ruleCfg.SpanKinds {
+			rule.spanKinds[kind] = true
+		}
+
+		for _, attribute := range ruleCfg.Attributes {
+			matcher := attributeMatcher{key: attribute.Key, value: attribute.Value}
+
+			if attribute.Pattern != "" {
+				pattern, err := regexp.Compile(attribute.Pattern)
+				if err != nil {
+					return nil, err
+				}
+				matcher.pattern = pattern
+			}
+
+			rule.attributes = append(rule.attributes, matcher)
+		}
+
+		processor.rules = append(processor.rules, rule)
+	}
+
+	return processor, nil
+}
+
+func (p *ExclusionProcessor) ProcessSpans(spans []model.Span) []model.Span {
+	// the rule that dropped each span, by span
+	dropped := make(map[spanKey]*exclusionRule)
+	// the spans dropped together with their descendants
+	roots := make([]spanKey, 0)
+
+	for _, span := range spans {
+		for i := range p.rules {
+			if !p.rules[i].matches(span) {
+				continue
+			}
+
+			dropped[keyOf(span)] = &p.rules[i]
+			if p.rules[i].cfg.DropDescendants {
+				roots = append(roots, keyOf(span))
+			}
+
+			break
+		}
+	}
+
+	if len(dropped) == 0 {
+		return spans
+	}
+
+	if len(roots) > 0 {
+		p.dropDescendants(spans, roots, dropped)
+	}
+
+	kept := spans[:0]
+	counts := make(map[string]int)
+
+	for _, span := range spans {
+		if rule, ex := dropped[keyOf(span)]; ex {
+			counts[rule.cfg.Name]++
+			continue
+		}
+
+		kept = append(kept, span)
+	}
+
+	for rule, count := range counts {
+		p.recorder.ExcludedSpans(rule, count)
+	}
+	p.recorder.SpansDropped(metrics.ReasonExclusion, len(dropped))
+
+	p.logger.Debug("Excluded spans", zap.Int("dropped", len(dropped)), zap.Any("rules", counts))
+
+	return kept
+}
+
+func (p *ExclusionProcessor) Name() string {
+	return "ExclusionProcessor"
+}
+
+// dropDescendants walks down from the given spans and attributes all their descendants to the same rule
+func (p *ExclusionProcessor) dropDescendants(spans []model.Span, roots []spanKey, dropped map[spanKey]*exclusionRule) {
+	children := make(map[spanKey][]spanKey)
+	for _, span := range spans {
+		if span.ParentID == "" {
+			continue
+		}
+
+		parent := spanKey{traceId: span.TraceID, spanId: span.ParentID}
+		children[parent] = append(children[parent], keyOf(span))
+	}
+
+	for len(roots) > 0 {
+		parent := roots[0]
+		roots = roots[1:]
+
+		for _, child := range children[parent] {
+			if _, ex := dropped[child]; ex {
+				continue
+			}
+
+			dropped[child] = dropped[parent]
+			roots = append(roots, child)
+		}
+	}
+}
+
+func (r *exclusionRule) matches(span model.Span) bool {
+	if r.cfg.Service != "" && r.cfg.Service != span.Data.ServiceName {
+		return false
+	}
+
+	if r.cfg.Operation != "" && r.cfg.Operation != span.Data.Operation {
+		return false
+	}
+
+	if r.operationPattern != nil && !r.operationPattern.MatchString(span.Data.Operation) {
+		return false
+	}
+
+	if len(r.spanKinds) > 0 && !r.spanKinds[span.Data.Kind] {
+		return false
+	}
+
+	if r.cfg.MaxDuration > 0 && time.Duration(span.Duration)*time.Millisecond >= r.cfg.MaxDuration {
+		return false
+	}
+
+	for _, attribute := range r.attributes {
+		value, ex := span.Data.Tags[attribute.key]
+		if !ex {
+			return false
+		}
+
+		if attribute.pattern != nil && !attribute.pattern.MatchString(value) {
+			return false
+		}
+
+		if attribute.pattern == nil && attribute.value != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func keyOf(span model.Span) spanKey {
+	return spanKey{traceId: span.TraceID, spanId: span.SpanID}
+}
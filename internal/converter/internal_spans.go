@@ -0,0 +1,110 @@
+package converter
+
+import (
+	"github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"go.uber.org/zap"
+)
+
+var _ BatchProcessor = (*InternalSpanFoldingProcessor)(nil)
+
+// InternalSpanFoldingProcessor folds intermediate spans into their nearest entry or exit ancestor within
+// the batch, either as annotations of the ancestor or without a trace. Children of folded spans are
+// re-parented to that ancestor, so that the trace tree stays connected. Intermediate spans without an
+// entry or exit ancestor in the batch are kept.
+type InternalSpanFoldingProcessor struct {
+	logger *zap.Logger
+	mode   string
+}
+
+func NewInternalSpanFoldingProcessor(logger *zap.Logger, mode string) *InternalSpanFoldingProcessor {
+	return &InternalSpanFoldingProcessor{
+		logger: logger,
+		mode:   mode,
+	}
+}
+
+func (p *InternalSpanFoldingProcessor) ProcessSpans(spans []model.Span) []model.Span {
+	index := make(map[spanKey]int)
+	for i, span := range spans {
+		index[keyOf(span)] = i
+	}
+
+	// the ancestor each folded span is folded into, by folded span
+	folded := make(map[spanKey]int)
+
+	for i, span := range spans {
+		if span.Kind != model.INSTANA_KIND_INTERMEDIATE {
+			continue
+		}
+
+		if ancestor, found := nearestEntryOrExit(spans, index, i); found {
+			folded[keyOf(span)] = ancestor
+		}
+	}
+
+	if len(folded) == 0 {
+		return spans
+	}
+
+	for i := range spans {
+		if ancestorIndex, ex := folded[keyOf(spans[i])]; ex {
+			// the ancestor takes over the errors of the folded span, so that the call still shows them
+			ancestor := &spans[ancestorIndex]
+			ancestor.Ec += spans[i].Ec
+
+			if p.mode == config.InternalSpansModeAnnotate {
+				ancestor.Data.Folded = append(ancestor.Data.Folded, model.FoldedSpan{
+					Operation: spans[i].Data.Operation,
+					Duration:  spans[i].Duration,
+					Ec:        spans[i].Ec,
+				})
+			}
+
+			continue
+		}
+
+		if ancestor, ex := folded[spanKey{traceId: spans[i].TraceID, spanId: spans[i].ParentID}]; ex {
+			spans[i].ParentID = spans[ancestor].SpanID
+		}
+	}
+
+	kept := make([]model.Span, 0, len(spans)-len(folded))
+	for _, span := range spans {
+		if _, ex := folded[keyOf(span)]; !ex {
+			kept = append(kept, span)
+		}
+	}
+
+	p.logger.Debug("Folded intermediate spans", zap.Int("folded", len(folded)), zap.String("mode", p.mode))
+
+	return kept
+}
+
+func (p *InternalSpanFoldingProcessor) Name() string {
+	return "InternalSpanFoldingProcessor"
+}
+
+// nearestEntryOrExit walks up the parents of a span until it finds an entry or exit span, it fails
+// if a parent is missing from the batch
+func nearestEntryOrExit(spans []model.Span, index map[spanKey]int, i int) (int, bool) {
+	visited := make(map[int]bool)
+
+	for !visited[i] {
+		visited[i] = true
+
+		parent, ex := index[spanKey{traceId: spans[i].TraceID, spanId: spans[i].ParentID}]
+		if spans[i].ParentID == "" || !ex {
+			return 0, false
+		}
+
+		if spans[parent].Kind != model.INSTANA_KIND_INTERMEDIATE {
+			return parent, true
+		}
+
+		i = parent
+	}
+
+	// a cycle of parents, which only malformed traces contain
+	return 0, false
+}
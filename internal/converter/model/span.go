@@ -17,14 +17,30 @@ const (
 	INSTANA_SPAN_KIND_CONSUMER = "consumer"
 	INSTANA_SPAN_KIND_INTERNAL = "internal"
 
+	INSTANA_SPAN_KIND_UNSPECIFIED = "unspecified"
+
+	// Instana's native span kinds
+	INSTANA_KIND_ENTRY        = 1
+	INSTANA_KIND_EXIT         = 2
+	INSTANA_KIND_INTERMEDIATE = 3
+
 	INSTANA_DATA_SERVICE      = "service"
 	INSTANA_DATA_OPERATION    = "operation"
 	INSTANA_DATA_TRACE_STATE  = "trace_state"
 	INSTANA_DATA_ERROR        = "error"
 	INSTANA_DATA_ERROR_DETAIL = "error_detail"
+
+	// INSTANA_DATA_SYNTHETIC_ENTRY marks entry spans created or re-kinded by the exporter
+	INSTANA_DATA_SYNTHETIC_ENTRY = "instana.synthetic_entry"
+
+	// INSTANA_TRACE_STATE_KEY is the tracestate member Instana tracers use to pass their trace and span IDs
+	INSTANA_TRACE_STATE_KEY = "in"
 )
 
+// BatchInfo describes a span aggregating several similar sibling spans. The duration of
+// a batch span is the sum of the aggregated spans' durations.
 type BatchInfo struct {
+	// Size is the number of aggregated spans
 	Size int `json:"s"`
 }
 
@@ -42,12 +58,28 @@ type TraceReference struct {
 	ParentID string `json:"p,omitempty"`
 }
 
+// OTelScopeData describes the instrumentation scope (library) that produced a span
+type OTelScopeData struct {
+	Name       string            `json:"name"`
+	Version    string            `json:"version,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// FoldedSpan annotates an entry or exit span with an intermediate span folded into it
+type FoldedSpan struct {
+	Operation string `json:"operation"`
+	Duration  uint64 `json:"d"`
+	Ec        int    `json:"ec,omitempty"`
+}
+
 type OTelSpanData struct {
 	Kind           string            `json:"kind"`
 	HasTraceParent bool              `json:"tp,omitempty"`
 	ServiceName    string            `json:"service"`
 	Operation      string            `json:"operation"`
 	TraceState     string            `json:"trace_state,omitempty"`
+	Scope          *OTelScopeData    `json:"scope,omitempty"`
+	Folded         []FoldedSpan      `json:"folded,omitempty"`
 	Tags           map[string]string `json:"tags,omitempty"`
 }
 
@@ -59,6 +91,7 @@ type Span struct {
 	Timestamp       uint64          `json:"ts"`
 	Duration        uint64          `json:"d"`
 	Name            string          `json:"n"`
+	Kind            int             `json:"k"`
 	From            *FromS          `json:"f"`
 	Batch           *BatchInfo      `json:"b,omitempty"`
 	Ec              int             `json:"ec,omitempty"`
@@ -68,13 +101,44 @@ type Span struct {
 	ForeignTrace    bool            `json:"tp,omitempty"`
 	Ancestor        *TraceReference `json:"ia,omitempty"`
 	Data            OTelSpanData    `json:"data,omitempty"`
+
+	// OTelTraceID is the original 128-bit OpenTelemetry trace ID. It is not sent and stays the same
+	// when batch processors rewrite the trace IDs, so that decisions per trace are consistent across batches.
+	OTelTraceID string `json:"-"`
+}
+
+// ConvertPDataScope converts an instrumentation scope, it returns nil for scopes without a name
+func ConvertPDataScope(scope pcommon.InstrumentationScope) *OTelScopeData {
+	if scope.Name() == "" {
+		return nil
+	}
+
+	scopeData := &OTelScopeData{
+		Name:    scope.Name(),
+		Version: scope.Version(),
+	}
+
+	if scope.Attributes().Len() > 0 {
+		scopeData.Attributes = make(map[string]string)
+
+		scope.Attributes().Range(func(k string, v pcommon.Value) bool {
+			scopeData.Attributes[k] = v.AsString()
+
+			return true
+		})
+	}
+
+	return scopeData
 }
 
-func ConvertPDataSpanToInstanaSpan(fromS FromS, otelSpan ptrace.Span, serviceName string, attributes pcommon.Map) (Span, error) {
+// ConvertPDataSpanToInstanaSpan converts an OpenTelemetry span, instanaKind is one of INSTANA_KIND_ENTRY,
+// INSTANA_KIND_EXIT or INSTANA_KIND_INTERMEDIATE as returned by DefaultInstanaKind
+func ConvertPDataSpanToInstanaSpan(fromS FromS, otelSpan ptrace.Span, serviceName string, instanaKind int, attributes pcommon.Map) (Span, error) {
 	traceId := convertTraceId(otelSpan.TraceID())
 
 	instanaSpan := Span{
 		Name:           OTEL_SPAN_TYPE,
+		Kind:           instanaKind,
 		TraceReference: TraceReference{},
 		Timestamp:      uint64(otelSpan.StartTimestamp()) / uint64(time.Millisecond),
 		Duration:       (uint64(otelSpan.EndTimestamp()) - uint64(otelSpan.StartTimestamp())) / uint64(time.Millisecond),
@@ -90,6 +154,7 @@ func ConvertPDataSpanToInstanaSpan(fromS FromS, otelSpan ptrace.Span, serviceNam
 
 	instanaSpan.TraceReference.TraceID = traceId[16:32]
 	instanaSpan.LongTraceID = traceId
+	instanaSpan.OTelTraceID = traceId
 
 	if !otelSpan.ParentSpanID().IsEmpty() {
 		instanaSpan.TraceReference.ParentID = convertSpanId(otelSpan.ParentSpanID())
@@ -97,11 +162,18 @@ func ConvertPDataSpanToInstanaSpan(fromS FromS, otelSpan ptrace.Span, serviceNam
 
 	instanaSpan.SpanID = convertSpanId(otelSpan.SpanID())
 
-	kind, isEntry := oTelKindToInstanaKind(otelSpan.Kind())
-	instanaSpan.Data.Kind = kind
+	instanaSpan.Data.Kind = SpanKindName(otelSpan.Kind())
 
-	if !otelSpan.ParentSpanID().IsEmpty() && isEntry {
+	// Entry spans with a remote parent continue a W3C trace. The trace is foreign unless the
+	// caller was traced by Instana, in which case it left its IDs in the "in" tracestate member.
+	if !otelSpan.ParentSpanID().IsEmpty() && instanaKind == INSTANA_KIND_ENTRY {
 		instanaSpan.Data.HasTraceParent = true
+		instanaSpan.ForeignTrace = true
+
+		if ancestor := ParseInstanaAncestor(otelSpan.TraceState()); ancestor != nil {
+			instanaSpan.Ancestor = ancestor
+			instanaSpan.ForeignTrace = ancestor.TraceID != instanaSpan.TraceID
+		}
 	}
 
 	instanaSpan.Data.ServiceName = serviceName
@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/hex"
+	"strings"
 
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
@@ -41,19 +42,83 @@ func convertSpanId(spanId pcommon.SpanID) string {
 	return hex.EncodeToString(spanBytes)
 }
 
-func oTelKindToInstanaKind(otelKind ptrace.SpanKind) (string, bool) {
+// SpanKindName returns the name of an OpenTelemetry span kind as used in the span data
+func SpanKindName(otelKind ptrace.SpanKind) string {
 	switch otelKind {
 	case ptrace.SpanKindServer:
-		return INSTANA_SPAN_KIND_SERVER, true
+		return INSTANA_SPAN_KIND_SERVER
 	case ptrace.SpanKindClient:
-		return INSTANA_SPAN_KIND_CLIENT, false
+		return INSTANA_SPAN_KIND_CLIENT
 	case ptrace.SpanKindProducer:
-		return INSTANA_SPAN_KIND_PRODUCER, false
+		return INSTANA_SPAN_KIND_PRODUCER
 	case ptrace.SpanKindConsumer:
-		return INSTANA_SPAN_KIND_CONSUMER, true
+		return INSTANA_SPAN_KIND_CONSUMER
 	case ptrace.SpanKindInternal:
-		return INSTANA_SPAN_KIND_INTERNAL, false
+		return INSTANA_SPAN_KIND_INTERNAL
+	case ptrace.SpanKindUnspecified:
+		return INSTANA_SPAN_KIND_UNSPECIFIED
 	default:
-		return "unknown", false
+		return "unknown"
 	}
 }
+
+// DefaultInstanaKind maps an OpenTelemetry span kind to the Instana entry, exit or intermediate kind.
+// Unspecified spans are treated as internal ones, as recommended by the OpenTelemetry specification.
+func DefaultInstanaKind(otelKind ptrace.SpanKind) int {
+	switch otelKind {
+	case ptrace.SpanKindServer, ptrace.SpanKindConsumer:
+		return INSTANA_KIND_ENTRY
+	case ptrace.SpanKindClient, ptrace.SpanKindProducer:
+		return INSTANA_KIND_EXIT
+	default:
+		return INSTANA_KIND_INTERMEDIATE
+	}
+}
+
+// parseTraceState splits a W3C tracestate into its list members. If a key is repeated, the
+// first (most recent) member wins as mandated by the specification.
+func parseTraceState(traceState ptrace.TraceState) map[string]string {
+	members := make(map[string]string)
+
+	for _, member := range strings.Split(string(traceState), ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(member), "=")
+		if !found || key == "" {
+			continue
+		}
+
+		if _, ex := members[key]; !ex {
+			members[key] = value
+		}
+	}
+
+	return members
+}
+
+// ParseInstanaAncestor parses the Instana "in=<traceId>;<spanId>" tracestate member into a reference
+// to the Instana span that called the current one. It returns nil if the member is missing or invalid.
+func ParseInstanaAncestor(traceState ptrace.TraceState) *TraceReference {
+	value, ex := parseTraceState(traceState)[INSTANA_TRACE_STATE_KEY]
+	if !ex {
+		return nil
+	}
+
+	traceId, spanId, found := strings.Cut(value, ";")
+	if !found || (len(traceId) != 16 && len(traceId) != 32) || len(spanId) != 16 {
+		return nil
+	}
+
+	if !isHex(traceId) || !isHex(spanId) {
+		return nil
+	}
+
+	return &TraceReference{
+		TraceID:  traceId[len(traceId)-16:],
+		ParentID: spanId,
+	}
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+
+	return err == nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 )
 
 func TestCanConvertSpanId(t *testing.T) {
@@ -12,3 +13,11 @@ func TestCanConvertSpanId(t *testing.T) {
 
 	assert.Equal(t, "010203040a0b0c0d", convertSpanId(pcommon.NewSpanID(bytes)))
 }
+
+func TestCanParseInstanaAncestor(t *testing.T) {
+	ancestor := ParseInstanaAncestor(ptrace.TraceState("rojo=00f067aa0ba902b7, in=00000000000000001234567890abcdef;0102030405060708,in=ffffffffffffffff;ffffffffffffffff"))
+
+	assert.Equal(t, &TraceReference{TraceID: "1234567890abcdef", ParentID: "0102030405060708"}, ancestor)
+	assert.Nil(t, ParseInstanaAncestor(ptrace.TraceState("in=notatraceid;0102030405060708")))
+	assert.Nil(t, ParseInstanaAncestor(ptrace.TraceStateEmpty))
+}
@@ -0,0 +1,151 @@
+package converter
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ibm-observability/instanaexporter/config"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const (
+	// placeholderSpanName resolves to the OpenTelemetry span name in operation name templates
+	placeholderSpanName = "span.name"
+)
+
+type templateSegment struct {
+	literal     string
+	placeholder string
+}
+
+type operationNameRule struct {
+	kinds    map[string]bool
+	segments []templateSegment
+}
+
+type operationNameRewrite struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// operationNamer builds Instana operation names according to the configured naming rules
+type operationNamer struct {
+	rules    []operationNameRule
+	rewrites []operationNameRewrite
+}
+
+func newOperationNamer(cfg config.OperationNamingConfig) (*operationNamer, error) {
+	namer := &operationNamer{}
+
+	for _, rule := range cfg.Rules {
+		kinds := make(map[string]bool)
+		for _, kind := range rule.SpanKinds {
+			kinds[kind] = true
+		}
+
+		namer.rules = append(namer.rules, operationNameRule{
+			kinds:    kinds,
+			segments: parseTemplate(rule.Template),
+		})
+	}
+
+	for _, rewrite := range cfg.Rewrites {
+		pattern, err := regexp.Compile(rewrite.Pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		namer.rewrites = append(namer.rewrites, operationNameRewrite{
+			pattern:     pattern,
+			replacement: rewrite.Replacement,
+		})
+	}
+
+	return namer, nil
+}
+
+// operationName returns the operation name of the first applicable rule, falling back to the span name
+func (n *operationNamer) operationName(kind string, otelSpan ptrace.Span, attributes pcommon.Map) string {
+	name := otelSpan.Name()
+
+	for _, rule := range n.rules {
+		if len(rule.kinds) > 0 && !rule.kinds[kind] {
+			continue
+		}
+
+		if value, ok := rule.render(otelSpan, attributes); ok {
+			name = value
+			break
+		}
+	}
+
+	for _, rewrite := range n.rewrites {
+		name = rewrite.pattern.ReplaceAllString(name, rewrite.replacement)
+	}
+
+	return name
+}
+
+// render fills the rule template, placeholders are resolved from span attributes first and
+// resource attributes second. It fails if any placeholder cannot be resolved.
+func (r *operationNameRule) render(otelSpan ptrace.Span, attributes pcommon.Map) (string, bool) {
+	var sb strings.Builder
+
+	for _, segment := range r.segments {
+		if segment.placeholder == "" {
+			sb.WriteString(segment.literal)
+			continue
+		}
+
+		value := lookupPlaceholder(segment.placeholder, otelSpan, attributes)
+		if value == "" {
+			return "", false
+		}
+
+		sb.WriteString(value)
+	}
+
+	return sb.String(), true
+}
+
+func lookupPlaceholder(placeholder string, otelSpan ptrace.Span, attributes pcommon.Map) string {
+	if placeholder == placeholderSpanName {
+		return otelSpan.Name()
+	}
+
+	if value, ex := otelSpan.Attributes().Get(placeholder); ex {
+		return value.AsString()
+	}
+
+	if value, ex := attributes.Get(placeholder); ex {
+		return value.AsString()
+	}
+
+	return ""
+}
+
+// parseTemplate splits a template into literal and placeholder segments. Templates are validated
+// by the configuration, so unbalanced braces are treated as literals.
+func parseTemplate(template string) []templateSegment {
+	segments := make([]templateSegment, 0)
+
+	for len(template) > 0 {
+		start := strings.IndexByte(template, '{')
+		end := strings.IndexByte(template, '}')
+
+		if start < 0 || end < start {
+			segments = append(segments, templateSegment{literal: template})
+			break
+		}
+
+		if start > 0 {
+			segments = append(segments, templateSegment{literal: template[:start]})
+		}
+
+		segments = append(segments, templateSegment{placeholder: template[start+1 : end]})
+		template = template[end+1:]
+	}
+
+	return segments
+}
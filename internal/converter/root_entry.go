@@ -0,0 +1,94 @@
+package converter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"go.uber.org/zap"
+)
+
+var _ BatchProcessor = (*RootEntryProcessor)(nil)
+
+// RootEntryProcessor makes sure every trace yields at least one Instana call. Root spans that are not entry
+// spans, as emitted by batch jobs and cron workers, either get a synthetic entry span as parent or are
+// turned into entry spans themselves. Both are marked with the INSTANA_DATA_SYNTHETIC_ENTRY tag.
+type RootEntryProcessor struct {
+	logger *zap.Logger
+	mode   string
+}
+
+func NewRootEntryProcessor(logger *zap.Logger, mode string) *RootEntryProcessor {
+	return &RootEntryProcessor{
+		logger: logger,
+		mode:   mode,
+	}
+}
+
+func (p *RootEntryProcessor) ProcessSpans(spans []model.Span) []model.Span {
+	entries := make([]model.Span, 0)
+
+	for i := range spans {
+		root := &spans[i]
+		if root.ParentID != "" || root.Kind == model.INSTANA_KIND_ENTRY {
+			continue
+		}
+
+		if p.mode == config.RootEntryModeRekind {
+			root.Kind = model.INSTANA_KIND_ENTRY
+			root.Data.Kind = model.INSTANA_SPAN_KIND_SERVER
+			root.Data.Tags[model.INSTANA_DATA_SYNTHETIC_ENTRY] = config.RootEntryModeRekind
+			continue
+		}
+
+		entry, err := synthesizeEntry(*root)
+		if err != nil {
+			p.logger.Debug("Failed to synthesize entry span", zap.Error(err))
+			continue
+		}
+
+		root.ParentID = entry.SpanID
+		entries = append(entries, entry)
+	}
+
+	if len(entries) > 0 {
+		p.logger.Debug("Synthesized entry spans", zap.Int("entries", len(entries)))
+	}
+
+	return append(spans, entries...)
+}
+
+func (p *RootEntryProcessor) Name() string {
+	return "RootEntryProcessor"
+}
+
+// synthesizeEntry creates an entry span covering the given root span
+func synthesizeEntry(root model.Span) (model.Span, error) {
+	spanId := make([]byte, 8)
+	if _, err := rand.Read(spanId); err != nil {
+		return model.Span{}, err
+	}
+
+	return model.Span{
+		TraceReference: model.TraceReference{TraceID: root.TraceID},
+		SpanID:         hex.EncodeToString(spanId),
+		LongTraceID:    root.LongTraceID,
+		Timestamp:      root.Timestamp,
+		Duration:       root.Duration,
+		Name:           root.Name,
+		Kind:           model.INSTANA_KIND_ENTRY,
+		From:           root.From,
+		Synthetic:      root.Synthetic,
+		OTelTraceID:    root.OTelTraceID,
+		Data: model.OTelSpanData{
+			Kind:        model.INSTANA_SPAN_KIND_SERVER,
+			ServiceName: root.Data.ServiceName,
+			Operation:   root.Data.Operation,
+			Scope:       root.Data.Scope,
+			Tags: map[string]string{
+				model.INSTANA_DATA_SYNTHETIC_ENTRY: config.RootEntryModeSynthesize,
+			},
+		},
+	}, nil
+}
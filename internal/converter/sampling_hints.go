@@ -0,0 +1,41 @@
+package converter
+
+import (
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const (
+	// openTelemetryTraceStateKey is the tracestate member of OpenTelemetry probability sampling
+	openTelemetryTraceStateKey = "ot"
+
+	// unsampledPValue is the p-value of a zero sampling probability
+	unsampledPValue = "63"
+)
+
+// isSampled inspects the sampling hints of a span. The OTLP span model of this collector version does not
+// carry the W3C trace flags, so the OpenTelemetry "ot" tracestate member and the sampling priority attribute are used.
+func isSampled(otelSpan ptrace.Span, priorityAttribute string) bool {
+	if priority, ex := otelSpan.Attributes().Get(priorityAttribute); ex && priorityAttribute != "" {
+		if value, err := strconv.ParseFloat(priority.AsString(), 64); err == nil && value <= 0 {
+			return false
+		}
+	}
+
+	for _, member := range strings.Split(string(otelSpan.TraceState()), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(member), "=")
+		if key != openTelemetryTraceStateKey {
+			continue
+		}
+
+		for _, field := range strings.Split(value, ";") {
+			if field == "p:"+unsampledPValue {
+				return false
+			}
+		}
+	}
+
+	return true
+}
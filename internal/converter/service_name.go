@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"strings"
+
+	"github.com/ibm-observability/instanaexporter/config"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	conventions "go.opentelemetry.io/collector/semconv/v1.8.0"
+)
+
+const (
+	unknownServicePrefix = "unknown_service"
+)
+
+// serviceNameResolver resolves Instana service names according to the configured resolution chain
+type serviceNameResolver struct {
+	cfg config.ServiceNameConfig
+}
+
+// resolve returns the service name shared by all spans of a resource and instrumentation scope
+func (r *serviceNameResolver) resolve(attributes pcommon.Map, scope pcommon.InstrumentationScope) string {
+	for _, source := range r.cfg.Sources {
+		if source == config.ServiceNameSourceScope {
+			if name := scope.Name(); usableServiceName(name) {
+				return name
+			}
+		} else if value, ex := attributes.Get(source); ex && usableServiceName(value.AsString()) {
+			// only names of the resource belong to its namespace
+			return r.withNamespace(attributes, value.AsString())
+		}
+	}
+
+	return r.cfg.Default
+}
+
+func (r *serviceNameResolver) withNamespace(attributes pcommon.Map, serviceName string) string {
+	if !r.cfg.UseNamespace {
+		return serviceName
+	}
+
+	if namespace, ex := attributes.Get(conventions.AttributeServiceNamespace); ex && namespace.AsString() != "" {
+		return namespace.AsString() + "/" + serviceName
+	}
+
+	return serviceName
+}
+
+func usableServiceName(serviceName string) bool {
+	return serviceName != "" && !strings.HasPrefix(serviceName, unknownServicePrefix)
+}
+
+// spanServiceName applies the per-span override attribute, if configured and present on the span
+func (r *serviceNameResolver) spanServiceName(serviceName string, otelSpan ptrace.Span) string {
+	if r.cfg.OverrideAttribute == "" {
+		return serviceName
+	}
+
+	if value, ex := otelSpan.Attributes().Get(r.cfg.OverrideAttribute); ex && value.AsString() != "" {
+		return value.AsString()
+	}
+
+	return serviceName
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/ibm-observability/instanaexporter/config"
 	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"github.com/ibm-observability/instanaexporter/internal/metrics"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	conventions "go.opentelemetry.io/collector/semconv/v1.8.0"
@@ -19,15 +20,45 @@ const (
 var _ Converter = (*SpanConverter)(nil)
 
 type SpanConverter struct {
-	logger *zap.Logger
+	logger              *zap.Logger
+	operationNamer      *operationNamer
+	serviceNameResolver *serviceNameResolver
+	correlator          *correlator
+	spanKindResolver    *spanKindResolver
+	errorClassifier     *errorClassifier
+	unsampled           config.UnsampledConfig
+	recorder            *metrics.Recorder
 }
 
-func (c *SpanConverter) AcceptsSpans(attributes pcommon.Map, spanSlice ptrace.SpanSlice) bool {
+func NewSpanConverter(logger *zap.Logger, recorder *metrics.Recorder, cfg *config.Config) (*SpanConverter, error) {
+	operationNamer, err := newOperationNamer(cfg.OperationNaming)
+	if err != nil {
+		return nil, err
+	}
+
+	errorClassifier, err := newErrorClassifier(cfg.ErrorClassification)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SpanConverter{
+		logger:              logger,
+		operationNamer:      operationNamer,
+		serviceNameResolver: &serviceNameResolver{cfg: cfg.ServiceName},
+		correlator:          &correlator{cfg: cfg.Correlation},
+		spanKindResolver:    newSpanKindResolver(cfg.SpanKind),
+		errorClassifier:     errorClassifier,
+		unsampled:           cfg.Unsampled,
+		recorder:            recorder,
+	}, nil
+}
+
+func (c *SpanConverter) AcceptsSpans(attributes pcommon.Map, scope pcommon.InstrumentationScope, spanSlice ptrace.SpanSlice) bool {
 
 	return true
 }
 
-func (c *SpanConverter) ConvertSpans(attributes pcommon.Map, spanSlice ptrace.SpanSlice) model.Bundle {
+func (c *SpanConverter) ConvertSpans(attributes pcommon.Map, scope pcommon.InstrumentationScope, spanSlice ptrace.SpanSlice) model.Bundle {
 	bundle := model.NewBundle()
 	spans := make([]model.Span, 0)
 
@@ -48,23 +79,65 @@ func (c *SpanConverter) ConvertSpans(attributes pcommon.Map, spanSlice ptrace.Sp
 	}
 
 	serviceName := ""
-	serviceNameValue, ex := attributes.Get(conventions.AttributeServiceName)
-	if ex {
+	if c.serviceNameResolver != nil {
+		serviceName = c.serviceNameResolver.resolve(attributes, scope)
+	} else if serviceNameValue, ex := attributes.Get(conventions.AttributeServiceName); ex {
 		serviceName = serviceNameValue.AsString()
 	}
 
+	scopeData := model.ConvertPDataScope(scope)
+	unsampled := 0
+
 	for i := 0; i < spanSlice.Len(); i++ {
 		otelSpan := spanSlice.At(i)
 
-		instanaSpan, err := model.ConvertPDataSpanToInstanaSpan(fromS, otelSpan, serviceName, attributes)
+		sampled := c.unsampled.Policy == "" || c.unsampled.Policy == config.UnsampledPolicySend ||
+			isSampled(otelSpan, c.unsampled.PriorityAttribute)
+
+		if !sampled && c.unsampled.Policy == config.UnsampledPolicyDrop {
+			unsampled++
+			continue
+		}
+
+		spanServiceName := serviceName
+		if c.serviceNameResolver != nil {
+			spanServiceName = c.serviceNameResolver.spanServiceName(serviceName, otelSpan)
+		}
+
+		instanaKind := model.DefaultInstanaKind(otelSpan.Kind())
+		if c.spanKindResolver != nil {
+			instanaKind = c.spanKindResolver.resolve(scope, otelSpan.Kind())
+		}
+
+		instanaSpan, err := model.ConvertPDataSpanToInstanaSpan(fromS, otelSpan, spanServiceName, instanaKind, attributes)
 		if err != nil {
 			c.logger.Debug(fmt.Sprintf("Error converting Open Telemetry span to Instana span: %s", err.Error()))
 			continue
 		}
 
+		instanaSpan.Data.Scope = scopeData
+
+		if c.operationNamer != nil {
+			instanaSpan.Data.Operation = c.operationNamer.operationName(instanaSpan.Data.Kind, otelSpan, attributes)
+		}
+
+		if c.errorClassifier != nil {
+			c.errorClassifier.classify(&instanaSpan, otelSpan)
+		}
+
+		if c.correlator != nil {
+			c.correlator.correlate(&instanaSpan, otelSpan)
+		}
+
+		if !sampled {
+			instanaSpan.Synthetic = true
+		}
+
 		spans = append(spans, instanaSpan)
 	}
 
+	c.recorder.SpansDropped(metrics.ReasonUnsampled, unsampled)
+
 	bundle.Spans = spans
 
 	return bundle
@@ -0,0 +1,67 @@
+package converter
+
+import (
+	"github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+var instanaKinds = map[string]int{
+	config.InstanaKindEntry:        model.INSTANA_KIND_ENTRY,
+	config.InstanaKindExit:         model.INSTANA_KIND_EXIT,
+	config.InstanaKindIntermediate: model.INSTANA_KIND_INTERMEDIATE,
+}
+
+type spanKindOverride struct {
+	scope     string
+	spanKinds map[string]bool
+	kind      int
+}
+
+// spanKindResolver determines the Instana kind of spans, honoring the configured overrides
+type spanKindResolver struct {
+	unspecified int
+	overrides   []spanKindOverride
+}
+
+func newSpanKindResolver(cfg config.SpanKindConfig) *spanKindResolver {
+	resolver := &spanKindResolver{
+		unspecified: instanaKinds[cfg.Unspecified],
+	}
+
+	for _, override := range cfg.Overrides {
+		spanKinds := make(map[string]bool)
+		for _, kind := range override.SpanKinds {
+			spanKinds[kind] = true
+		}
+
+		resolver.overrides = append(resolver.overrides, spanKindOverride{
+			scope:     override.Scope,
+			spanKinds: spanKinds,
+			kind:      instanaKinds[override.Kind],
+		})
+	}
+
+	return resolver
+}
+
+func (r *spanKindResolver) resolve(scope pcommon.InstrumentationScope, otelKind ptrace.SpanKind) int {
+	for _, override := range r.overrides {
+		if override.scope != scope.Name() {
+			continue
+		}
+
+		if len(override.spanKinds) > 0 && !override.spanKinds[model.SpanKindName(otelKind)] {
+			continue
+		}
+
+		return override.kind
+	}
+
+	if otelKind == ptrace.SpanKindUnspecified && r.unspecified != 0 {
+		return r.unspecified
+	}
+
+	return model.DefaultInstanaKind(otelKind)
+}
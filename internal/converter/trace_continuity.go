@@ -0,0 +1,52 @@
+package converter
+
+import (
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"go.uber.org/zap"
+)
+
+var _ BatchProcessor = (*TraceContinuityProcessor)(nil)
+
+// TraceContinuityProcessor moves OpenTelemetry traces that were started by an Instana tracer onto the
+// Instana trace ID, so that they render as one trace. Only spans within the same batch as the entry span
+// carrying the Instana ancestor are moved, spans of the trace exported in other batches keep the
+// OpenTelemetry trace ID. The long trace ID is kept on all spans for W3C correlation.
+type TraceContinuityProcessor struct {
+	logger *zap.Logger
+}
+
+func (p *TraceContinuityProcessor) ProcessSpans(spans []model.Span) []model.Span {
+	instanaTraceIds := make(map[string]string)
+
+	for _, span := range spans {
+		if span.Ancestor == nil || span.Ancestor.TraceID == span.TraceID {
+			continue
+		}
+
+		if _, ex := instanaTraceIds[span.LongTraceID]; !ex {
+			instanaTraceIds[span.LongTraceID] = span.Ancestor.TraceID
+		}
+	}
+
+	if len(instanaTraceIds) == 0 {
+		return spans
+	}
+
+	for i := range spans {
+		traceId, ex := instanaTraceIds[spans[i].LongTraceID]
+		if !ex {
+			continue
+		}
+
+		spans[i].TraceID = traceId
+		spans[i].ForeignTrace = false
+	}
+
+	p.logger.Debug("Moved traces onto Instana trace IDs", zap.Int("traces", len(instanaTraceIds)))
+
+	return spans
+}
+
+func (p *TraceContinuityProcessor) Name() string {
+	return "TraceContinuityProcessor"
+}
@@ -0,0 +1,65 @@
+package deadletter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// FilePrefix is the prefix of dead-letter files
+const FilePrefix = "instana-dead-letter"
+
+// Record is a bundle the Instana acceptor rejected permanently, stored to be replayed later. Bundles that could not
+// be marshalled are stored without content, identified by the IDs of their spans.
+type Record struct {
+	Time        time.Time `json:"time"`
+	Destination string    `json:"destination"`
+
+	// Headers are the request headers without the agent key
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Error describes why the bundle was rejected
+	Error string `json:"error"`
+
+	// StatusCode and Response are the acceptor's response, if it responded
+	StatusCode int    `json:"status_code,omitempty"`
+	Response   string `json:"response,omitempty"`
+
+	// Spans is the number of spans in the bundle
+	Spans int `json:"spans"`
+	// SpanIDs identify the spans of a bundle that could not be marshalled
+	SpanIDs []SpanID `json:"span_ids,omitempty"`
+	// Bundle is the rejected bundle as it was sent, null if it could not be marshalled
+	Bundle json.RawMessage `json:"bundle"`
+}
+
+// SpanID identifies a span of a bundle by its Instana trace and span IDs
+type SpanID struct {
+	TraceID string `json:"t"`
+	SpanID  string `json:"s"`
+}
+
+// ReadFile reads the records of a dead-letter file
+func ReadFile(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := make([]Record, 0)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
@@ -0,0 +1,82 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"github.com/ibm-observability/instanaexporter/internal/filesink"
+)
+
+func TestRecordsRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	bundle := model.Bundle{Spans: []model.Span{{SpanID: "0102030405060708", Name: "otel", Data: model.OTelSpanData{ServiceName: "shop"}}}}
+	raw, err := bundle.Marshal()
+	require.NoError(t, err)
+
+	written := []Record{
+		{
+			Time:        time.Unix(1660000000, 0).UTC(),
+			Destination: "default",
+			Headers:     map[string]string{"X-Instana-Host": "host-1"},
+			Error:       "the Instana acceptor responded with HTTP status 400",
+			StatusCode:  400,
+			Response:    "invalid span",
+			Spans:       1,
+			Bundle:      raw,
+		},
+		{
+			Time:        time.Unix(1660000001, 0).UTC(),
+			Destination: "team-a",
+			Error:       "the Instana acceptor responded with HTTP status 413",
+			StatusCode:  413,
+			Spans:       1,
+			Bundle:      raw,
+		},
+		{
+			Time:        time.Unix(1660000002, 0).UTC(),
+			Destination: "default",
+			Error:       "failed to marshal the bundle of destination \"default\"",
+			Spans:       2,
+			SpanIDs:     []SpanID{{TraceID: "0a0b0c0d0e0f0102", SpanID: "0102030405060708"}, {TraceID: "0a0b0c0d0e0f0102", SpanID: "0807060504030201"}},
+			// a bundle that could not be marshalled is written as null
+			Bundle: json.RawMessage("null"),
+		},
+	}
+
+	w, err := filesink.NewWriter(dir, FilePrefix, 1024*1024, 0, 0)
+	require.NoError(t, err)
+	for _, record := range written {
+		require.NoError(t, w.Write(record))
+	}
+	require.NoError(t, w.Close())
+
+	files, err := filesink.Files(dir, FilePrefix)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	read, err := ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Equal(t, written, read)
+
+	// the bundle is replayed as it was sent
+	var replayed model.Bundle
+	require.NoError(t, json.Unmarshal(read[1].Bundle, &replayed))
+	assert.Equal(t, bundle.Spans[0].SpanID, replayed.Spans[0].SpanID)
+	assert.Equal(t, "shop", replayed.Spans[0].Data.ServiceName)
+}
+
+func TestReadFileReportsMalformedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FilePrefix+".jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{\"destination\":\"default\",\"bundle\":{}}\nnot json\n"), 0600))
+
+	_, err := ReadFile(path)
+	assert.ErrorContains(t, err, ":2:")
+}
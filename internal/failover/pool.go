@@ -0,0 +1,113 @@
+package failover
+
+import (
+	"sync"
+)
+
+// Pool tracks the health of an ordered list of endpoints. The first healthy endpoint is preferred; an endpoint that
+// failed is skipped by requests until a probe outside of the requests found it healthy again.
+type Pool struct {
+	mu        sync.Mutex
+	endpoints []string
+	unhealthy []bool
+	active    int
+}
+
+// NewPool creates a pool of the given endpoints, in order of preference
+func NewPool(endpoints []string) *Pool {
+	return &Pool{
+		endpoints: endpoints,
+		unhealthy: make([]bool, len(endpoints)),
+	}
+}
+
+// Active returns the endpoint the last successful request was sent to
+func (p *Pool) Active() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.endpoints[p.active]
+}
+
+// Attempts returns the endpoints a request should be tried with, in order. Only healthy endpoints are tried, unless
+// all endpoints are unhealthy, in which case all of them are the last resort.
+func (p *Pool) Attempts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	attempts := make([]string, 0, len(p.endpoints))
+	for i, endpoint := range p.endpoints {
+		if !p.unhealthy[i] {
+			attempts = append(attempts, endpoint)
+		}
+	}
+
+	if len(attempts) == 0 {
+		return append(attempts, p.endpoints...)
+	}
+
+	return attempts
+}
+
+// Unhealthy returns the endpoints that failed and have to be probed before requests are sent to them again
+func (p *Pool) Unhealthy() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	unhealthy := make([]string, 0)
+	for i, endpoint := range p.endpoints {
+		if p.unhealthy[i] {
+			unhealthy = append(unhealthy, endpoint)
+		}
+	}
+
+	return unhealthy
+}
+
+// Failed marks the endpoint unhealthy until it recovered
+func (p *Pool) Failed(endpoint string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if i := p.indexOf(endpoint); i >= 0 {
+		p.unhealthy[i] = true
+	}
+}
+
+// Recovered marks the endpoint healthy after a successful probe, so that requests prefer it again by its position
+func (p *Pool) Recovered(endpoint string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if i := p.indexOf(endpoint); i >= 0 {
+		p.unhealthy[i] = false
+	}
+}
+
+// Succeeded marks the endpoint healthy and makes it the active one. It reports whether the active endpoint changed.
+func (p *Pool) Succeeded(endpoint string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := p.indexOf(endpoint)
+	if i < 0 {
+		return false
+	}
+
+	p.unhealthy[i] = false
+
+	switched := i != p.active
+	p.active = i
+
+	return switched
+}
+
+func (p *Pool) indexOf(endpoint string) int {
+	for i, e := range p.endpoints {
+		if e == endpoint {
+			return i
+		}
+	}
+
+	return -1
+}
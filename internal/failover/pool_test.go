@@ -0,0 +1,39 @@
+package failover
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolFailsOverAndBack(t *testing.T) {
+	pool := NewPool([]string{"primary", "secondary", "tertiary"})
+
+	assert.Equal(t, []string{"primary", "secondary", "tertiary"}, pool.Attempts())
+
+	pool.Failed("primary")
+	assert.Equal(t, []string{"secondary", "tertiary"}, pool.Attempts())
+	assert.Equal(t, []string{"primary"}, pool.Unhealthy())
+	assert.True(t, pool.Succeeded("secondary"))
+	assert.Equal(t, "secondary", pool.Active())
+
+	// requests never probe the failed endpoint, only a successful probe brings it back
+	assert.Equal(t, []string{"secondary", "tertiary"}, pool.Attempts())
+
+	pool.Recovered("primary")
+	assert.Empty(t, pool.Unhealthy())
+	assert.Equal(t, []string{"primary", "secondary", "tertiary"}, pool.Attempts())
+	assert.Equal(t, "secondary", pool.Active())
+	assert.True(t, pool.Succeeded("primary"))
+	assert.False(t, pool.Succeeded("primary"))
+	assert.Equal(t, "primary", pool.Active())
+}
+
+func TestPoolTriesUnhealthyEndpointsAsLastResort(t *testing.T) {
+	pool := NewPool([]string{"primary", "secondary"})
+
+	pool.Failed("secondary")
+	pool.Failed("primary")
+
+	assert.Equal(t, []string{"primary", "secondary"}, pool.Attempts())
+}
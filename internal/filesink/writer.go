@@ -0,0 +1,160 @@
+package filesink
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fileSuffix = ".jsonl"
+
+	// fileTimeFormat sorts lexicographically in chronological order
+	fileTimeFormat = "20060102T150405.000000000Z"
+)
+
+// Writer writes JSON lines to files in a directory. A file is rotated once it would exceed the maximum size; rotated
+// files are removed once there are more than the maximum number of files or once they exceed the maximum age.
+type Writer struct {
+	mu       sync.Mutex
+	dir      string
+	prefix   string
+	maxSize  int64
+	maxFiles int
+	maxAge   time.Duration
+	file     *os.File
+	size     int64
+	now      func() time.Time
+}
+
+// NewWriter creates a writer of files named <prefix>-<creation time>.jsonl in dir, creating dir if needed.
+// maxFiles and maxAge are not enforced when zero.
+func NewWriter(dir string, prefix string, maxSize int64, maxFiles int, maxAge time.Duration) (*Writer, error) {
+	return newWriter(dir, prefix, maxSize, maxFiles, maxAge, time.Now)
+}
+
+func newWriter(dir string, prefix string, maxSize int64, maxFiles int, maxAge time.Duration, now func() time.Time) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	return &Writer{
+		dir:      dir,
+		prefix:   prefix,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		maxAge:   maxAge,
+		now:      now,
+	}, nil
+}
+
+// Write appends v as one JSON line
+func (w *Writer) Write(v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil || (w.size > 0 && w.size+int64(len(line)) > w.maxSize) {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+
+	return err
+}
+
+// Close closes the current file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+
+	return err
+}
+
+// rotate closes the current file, opens a new one and removes the files beyond the limits; the caller must hold the lock
+func (w *Writer) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+		w.file = nil
+	}
+
+	name := filepath.Join(w.dir, w.prefix+"-"+w.now().UTC().Format(fileTimeFormat)+fileSuffix)
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+
+	w.file = file
+	w.size = 0
+
+	return w.cleanup()
+}
+
+// cleanup removes the oldest files beyond the limits, never the current one
+func (w *Writer) cleanup() error {
+	files, err := Files(w.dir, w.prefix)
+	if err != nil {
+		return err
+	}
+
+	// the current file is the newest one
+	files = files[:len(files)-1]
+
+	for i, name := range files {
+		remove := w.maxFiles > 0 && len(files)-i >= w.maxFiles
+		if !remove && w.maxAge > 0 {
+			if info, err := os.Stat(name); err == nil && w.now().Sub(info.ModTime()) > w.maxAge {
+				remove = true
+			}
+		}
+
+		if remove {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Files returns the paths of the files written with the prefix in dir, oldest first
+func Files(dir string, prefix string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, prefix+"-") && strings.HasSuffix(name, fileSuffix) {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
@@ -0,0 +1,51 @@
+package filesink
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type line struct {
+	Value string `json:"value"`
+}
+
+func TestWriterRotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Unix(0, 0)
+	w, err := newWriter(dir, "test", 40, 2, 0, func() time.Time { return now })
+	require.NoError(t, err)
+
+	for _, value := range []string{"first", "second", "third"} {
+		now = now.Add(time.Second)
+		require.NoError(t, w.Write(line{Value: value}))
+	}
+	require.NoError(t, w.Close())
+
+	files, err := Files(dir, "test")
+	require.NoError(t, err)
+	require.Len(t, files, 2, "the oldest file exceeds the maximum number of files")
+
+	content, err := os.ReadFile(files[1])
+	require.NoError(t, err)
+	assert.Equal(t, "{\"value\":\"third\"}\n", string(content))
+}
+
+func TestWriterRemovesOldFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	w, err := newWriter(dir, "test", 1, 0, time.Hour, func() time.Time { return now })
+	require.NoError(t, err)
+
+	require.NoError(t, w.Write(line{Value: "old"}))
+	now = now.Add(2 * time.Hour)
+	require.NoError(t, w.Write(line{Value: "new"}))
+	require.NoError(t, w.Close())
+
+	files, err := Files(dir, "test")
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,74 @@
+package inflight
+
+import (
+	"context"
+	"sync"
+)
+
+// Gate bounds the bytes of the requests in flight. Acquiring blocks while the bound would be exceeded, which
+// back-pressures the callers. A request larger than the bound is let through alone, so that it cannot block forever.
+// A nil Gate does not bound anything.
+type Gate struct {
+	mu       sync.Mutex
+	limit    int64
+	used     int64
+	released chan struct{}
+}
+
+// NewGate creates a gate letting at most limit bytes through at once
+func NewGate(limit int64) *Gate {
+	return &Gate{
+		limit:    limit,
+		released: make(chan struct{}),
+	}
+}
+
+// Acquire blocks until n bytes fit into the bound or the context is done
+func (g *Gate) Acquire(ctx context.Context, n int64) error {
+	if g == nil {
+		return nil
+	}
+
+	for {
+		g.mu.Lock()
+		if g.used == 0 || g.used+n <= g.limit {
+			g.used += n
+			g.mu.Unlock()
+			return nil
+		}
+		released := g.released
+		g.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release returns n bytes acquired before and wakes up the waiting callers
+func (g *Gate) Release(n int64) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.used -= n
+	close(g.released)
+	g.released = make(chan struct{})
+}
+
+// InFlight returns the bytes currently in flight
+func (g *Gate) InFlight() int64 {
+	if g == nil {
+		return 0
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.used
+}
@@ -0,0 +1,48 @@
+package inflight
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGateBlocksUntilReleased(t *testing.T) {
+	gate := NewGate(100)
+
+	require.NoError(t, gate.Acquire(context.Background(), 60))
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- gate.Acquire(context.Background(), 60)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("expected the second request to wait for the first one")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	gate.Release(60)
+	require.NoError(t, <-acquired)
+	assert.Equal(t, int64(60), gate.InFlight())
+}
+
+func TestGateLetsOversizedRequestsThroughAlone(t *testing.T) {
+	gate := NewGate(100)
+
+	require.NoError(t, gate.Acquire(context.Background(), 500))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, gate.Acquire(ctx, 1), context.DeadlineExceeded)
+}
+
+func TestNilGateDoesNotBound(t *testing.T) {
+	var gate *Gate
+
+	assert.NoError(t, gate.Acquire(context.Background(), 1<<40))
+	gate.Release(1 << 40)
+}
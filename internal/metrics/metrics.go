@@ -0,0 +1,322 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"go.opentelemetry.io/collector/config"
+)
+
+const (
+	metricPrefix = "exporter/instana/"
+
+	// reasons spans are dropped before being sent
+	ReasonUnsampled = "unsampled"
+	ReasonSampling  = "sampling"
+	ReasonExclusion = "exclusion"
+	ReasonRateLimit = "rate_limit"
+	ReasonMarshal   = "marshal"
+
+	// sampling decisions
+	DecisionSampled = "sampled"
+	DecisionError   = "error"
+	DecisionSlow    = "slow"
+	DecisionDropped = "dropped"
+
+	// request results
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+var (
+	tagExporter    = tag.MustNewKey("exporter")
+	tagReason      = tag.MustNewKey("reason")
+	tagDecision    = tag.MustNewKey("decision")
+	tagService     = tag.MustNewKey("service")
+	tagRule        = tag.MustNewKey("rule")
+	tagDestination = tag.MustNewKey("destination")
+	tagResult      = tag.MustNewKey("result")
+	tagEndpoint    = tag.MustNewKey("endpoint")
+
+	statRequests         = stats.Int64("requests", "Number of requests sent to Instana, by destination and result", stats.UnitDimensionless)
+	statSentSpans        = stats.Int64("sent_spans", "Number of spans sent to Instana, by destination", stats.UnitDimensionless)
+	statEndpointFailures = stats.Int64("endpoint_failures", "Number of requests that failed because an endpoint was unavailable", stats.UnitDimensionless)
+	statEndpointSwitches = stats.Int64("endpoint_switches", "Number of times a destination switched its active endpoint", stats.UnitDimensionless)
+	statBreakerState     = stats.Int64("circuit_breaker_state", "State of the circuit breaker of a destination: 0 closed, 1 open, 2 half-open", stats.UnitDimensionless)
+	statClockOffset      = stats.Int64("clock_offset", "Estimated offset of the local clock to the Instana backend", stats.UnitMilliseconds)
+	statDeadLettered     = stats.Int64("dead_lettered_bundles", "Number of permanently rejected bundles written to the dead-letter files", stats.UnitDimensionless)
+	statInFlightBytes    = stats.Int64("in_flight_bytes", "Bytes of the requests in flight to Instana", stats.UnitBytes)
+	statHealthy          = stats.Int64("destination_healthy", "Result of the last health check of a destination: 1 healthy, 0 unhealthy", stats.UnitDimensionless)
+	statSpansDropped     = stats.Int64("spans_dropped", "Number of spans dropped before sending them to Instana", stats.UnitDimensionless)
+	statExcludedSpans    = stats.Int64("excluded_spans", "Number of spans dropped by an exclusion rule", stats.UnitDimensionless)
+	statRateLimitedSpans = stats.Int64("rate_limited_spans", "Number of spans shed because they exceeded the span budget", stats.UnitDimensionless)
+	statSampledSpans     = stats.Int64("sampler_spans", "Number of spans seen by the sampler, by decision", stats.UnitDimensionless)
+	statEffectiveSampled = stats.Float64("sampler_effective_percentage", "Percentage of spans kept by the sampler in the last batch", stats.UnitDimensionless)
+)
+
+// registerOnce guards the registration of the views, registerErr holds its result
+var (
+	registerOnce sync.Once
+	registerErr  error
+)
+
+// RegisterViews registers the metric views once per process. Every exporter instance records into the
+// same views, tagged with its ID, so later calls return the result of the first registration.
+func RegisterViews() error {
+	registerOnce.Do(func() {
+		registerErr = view.Register(MetricViews()...)
+	})
+
+	return registerErr
+}
+
+// MetricViews returns the views of the exporter's metrics
+func MetricViews() []*view.View {
+	return []*view.View{
+		{
+			Name:        metricPrefix + statRequests.Name(),
+			Measure:     statRequests,
+			Description: statRequests.Description(),
+			TagKeys:     []tag.Key{tagExporter, tagDestination, tagResult},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + statSentSpans.Name(),
+			Measure:     statSentSpans,
+			Description: statSentSpans.Description(),
+			TagKeys:     []tag.Key{tagExporter, tagDestination},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + statEndpointFailures.Name(),
+			Measure:     statEndpointFailures,
+			Description: statEndpointFailures.Description(),
+			TagKeys:     []tag.Key{tagExporter, tagDestination, tagEndpoint},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + statEndpointSwitches.Name(),
+			Measure:     statEndpointSwitches,
+			Description: statEndpointSwitches.Description(),
+			TagKeys:     []tag.Key{tagExporter, tagDestination, tagEndpoint},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + statBreakerState.Name(),
+			Measure:     statBreakerState,
+			Description: statBreakerState.Description(),
+			TagKeys:     []tag.Key{tagExporter, tagDestination},
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        metricPrefix + statClockOffset.Name(),
+			Measure:     statClockOffset,
+			Description: statClockOffset.Description(),
+			TagKeys:     []tag.Key{tagExporter},
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        metricPrefix + statDeadLettered.Name(),
+			Measure:     statDeadLettered,
+			Description: statDeadLettered.Description(),
+			TagKeys:     []tag.Key{tagExporter, tagDestination},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + statInFlightBytes.Name(),
+			Measure:     statInFlightBytes,
+			Description: statInFlightBytes.Description(),
+			TagKeys:     []tag.Key{tagExporter},
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        metricPrefix + statHealthy.Name(),
+			Measure:     statHealthy,
+			Description: statHealthy.Description(),
+			TagKeys:     []tag.Key{tagExporter, tagDestination},
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        metricPrefix + statSpansDropped.Name(),
+			Measure:     statSpansDropped,
+			Description: statSpansDropped.Description(),
+			TagKeys:     []tag.Key{tagExporter, tagReason},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + statExcludedSpans.Name(),
+			Measure:     statExcludedSpans,
+			Description: statExcludedSpans.Description(),
+			TagKeys:     []tag.Key{tagExporter, tagRule},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + statRateLimitedSpans.Name(),
+			Measure:     statRateLimitedSpans,
+			Description: statRateLimitedSpans.Description(),
+			TagKeys:     []tag.Key{tagExporter, tagService},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + statSampledSpans.Name(),
+			Measure:     statSampledSpans,
+			Description: statSampledSpans.Description(),
+			TagKeys:     []tag.Key{tagExporter, tagService, tagDecision},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        metricPrefix + statEffectiveSampled.Name(),
+			Measure:     statEffectiveSampled,
+			Description: statEffectiveSampled.Description(),
+			TagKeys:     []tag.Key{tagExporter, tagService},
+			Aggregation: view.LastValue(),
+		},
+	}
+}
+
+// Recorder records the metrics of one exporter instance. A nil Recorder records nothing, so that
+// components can be used without metrics, e.g. in tests.
+type Recorder struct {
+	exporter string
+}
+
+func NewRecorder(id config.ComponentID) *Recorder {
+	return &Recorder{exporter: id.String()}
+}
+
+// Request records a request sent to a destination and the spans it carried, if it succeeded
+func (r *Recorder) Request(destination string, result string, spans int) {
+	if r == nil {
+		return
+	}
+
+	r.record([]tag.Mutator{tag.Upsert(tagDestination, destination), tag.Upsert(tagResult, result)}, statRequests.M(1))
+
+	if result == ResultSuccess {
+		r.record([]tag.Mutator{tag.Upsert(tagDestination, destination)}, statSentSpans.M(int64(spans)))
+	}
+}
+
+// EndpointFailure records a request to an endpoint of a destination that failed because the endpoint was unavailable
+func (r *Recorder) EndpointFailure(destination string, endpoint string) {
+	if r == nil {
+		return
+	}
+
+	r.record([]tag.Mutator{tag.Upsert(tagDestination, destination), tag.Upsert(tagEndpoint, endpoint)}, statEndpointFailures.M(1))
+}
+
+// EndpointSwitch records a destination switching its active endpoint to the given one
+func (r *Recorder) EndpointSwitch(destination string, endpoint string) {
+	if r == nil {
+		return
+	}
+
+	r.record([]tag.Mutator{tag.Upsert(tagDestination, destination), tag.Upsert(tagEndpoint, endpoint)}, statEndpointSwitches.M(1))
+}
+
+// CircuitBreakerState records the state of the circuit breaker of a destination
+func (r *Recorder) CircuitBreakerState(destination string, state int) {
+	if r == nil {
+		return
+	}
+
+	r.record([]tag.Mutator{tag.Upsert(tagDestination, destination)}, statBreakerState.M(int64(state)))
+}
+
+// ClockOffset records the estimated offset of the local clock to the Instana backend
+func (r *Recorder) ClockOffset(offset time.Duration) {
+	if r == nil {
+		return
+	}
+
+	r.record(nil, statClockOffset.M(offset.Milliseconds()))
+}
+
+// DeadLettered records a rejected bundle of a destination written to the dead-letter files
+func (r *Recorder) DeadLettered(destination string) {
+	if r == nil {
+		return
+	}
+
+	r.record([]tag.Mutator{tag.Upsert(tagDestination, destination)}, statDeadLettered.M(1))
+}
+
+// InFlightBytes records the bytes of the requests in flight
+func (r *Recorder) InFlightBytes(bytes int64) {
+	if r == nil {
+		return
+	}
+
+	r.record(nil, statInFlightBytes.M(bytes))
+}
+
+// DestinationHealth records the result of the last health check of a destination
+func (r *Recorder) DestinationHealth(destination string, healthy bool) {
+	if r == nil {
+		return
+	}
+
+	var value int64
+	if healthy {
+		value = 1
+	}
+
+	r.record([]tag.Mutator{tag.Upsert(tagDestination, destination)}, statHealthy.M(value))
+}
+
+// SpansDropped records spans dropped for the given reason
+func (r *Recorder) SpansDropped(reason string, count int) {
+	if r == nil || count == 0 {
+		return
+	}
+
+	r.record([]tag.Mutator{tag.Upsert(tagReason, reason)}, statSpansDropped.M(int64(count)))
+}
+
+// ExcludedSpans records spans dropped by the named exclusion rule
+func (r *Recorder) ExcludedSpans(rule string, count int) {
+	if r == nil || count == 0 {
+		return
+	}
+
+	r.record([]tag.Mutator{tag.Upsert(tagRule, rule)}, statExcludedSpans.M(int64(count)))
+}
+
+// RateLimitedSpans records spans of a service shed by the rate limit
+func (r *Recorder) RateLimitedSpans(service string, count int) {
+	if r == nil || count == 0 {
+		return
+	}
+
+	r.record([]tag.Mutator{tag.Upsert(tagService, service)}, statRateLimitedSpans.M(int64(count)))
+}
+
+// SampledSpans records the sampling decision made for spans of a service
+func (r *Recorder) SampledSpans(service string, decision string, count int) {
+	if r == nil || count == 0 {
+		return
+	}
+
+	r.record([]tag.Mutator{tag.Upsert(tagService, service), tag.Upsert(tagDecision, decision)}, statSampledSpans.M(int64(count)))
+}
+
+// EffectiveSamplePercentage records the percentage of spans of a service kept in the last batch
+func (r *Recorder) EffectiveSamplePercentage(service string, percentage float64) {
+	if r == nil {
+		return
+	}
+
+	r.record([]tag.Mutator{tag.Upsert(tagService, service)}, statEffectiveSampled.M(percentage))
+}
+
+func (r *Recorder) record(mutators []tag.Mutator, measurement stats.Measurement) {
+	mutators = append(mutators, tag.Upsert(tagExporter, r.exporter))
+
+	_ = stats.RecordWithTags(context.Background(), mutators, measurement)
+}
@@ -0,0 +1,189 @@
+package ratelimit
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"github.com/ibm-observability/instanaexporter/internal/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	// warnInterval is the minimum interval between two warnings about shed spans
+	warnInterval = 10 * time.Second
+
+	// evictInterval is the minimum interval between two sweeps for idle service buckets
+	evictInterval = time.Minute
+)
+
+// Limiter enforces the span budget with token buckets, globally and per service. Erroneous spans
+// are admitted first, so that they are kept preferentially when spans have to be shed.
+type Limiter struct {
+	logger   *zap.Logger
+	recorder *metrics.Recorder
+	cfg      config.RateLimitConfig
+	now      func() time.Time
+
+	mu        sync.Mutex
+	global    *tokenBucket
+	services  map[string]*tokenBucket
+	lastEvict time.Time
+	lastWarn  time.Time
+	shed      int
+}
+
+func NewLimiter(logger *zap.Logger, recorder *metrics.Recorder, cfg config.RateLimitConfig) *Limiter {
+	return newLimiter(logger, recorder, cfg, time.Now)
+}
+
+func newLimiter(logger *zap.Logger, recorder *metrics.Recorder, cfg config.RateLimitConfig, now func() time.Time) *Limiter {
+	limiter := &Limiter{
+		logger:    logger,
+		recorder:  recorder,
+		cfg:       cfg,
+		now:       now,
+		services:  make(map[string]*tokenBucket),
+		lastEvict: now(),
+	}
+
+	if cfg.SpansPerSecond > 0 {
+		limiter.global = newTokenBucket(cfg.SpansPerSecond, cfg.Burst, now())
+	}
+
+	return limiter
+}
+
+func (l *Limiter) ProcessSpans(spans []model.Span) []model.Span {
+	// admit erroneous spans first, keeping the original order otherwise
+	order := make([]int, len(spans))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return spans[order[i]].Ec > 0 && spans[order[j]].Ec == 0
+	})
+
+	admitted := make([]bool, len(spans))
+	shed := make(map[string]int)
+
+	l.mu.Lock()
+
+	now := l.now()
+	if l.global != nil {
+		l.global.refill(now)
+	}
+
+	refilled := make(map[string]bool)
+	for _, i := range order {
+		service := spans[i].Data.ServiceName
+
+		bucket := l.serviceBucket(service, now)
+		if bucket != nil && !refilled[service] {
+			bucket.refill(now)
+			refilled[service] = true
+		}
+
+		if (l.global != nil && !l.global.available()) || (bucket != nil && !bucket.available()) {
+			shed[service]++
+			continue
+		}
+
+		if l.global != nil {
+			l.global.take()
+		}
+		if bucket != nil {
+			bucket.take()
+		}
+
+		admitted[i] = true
+	}
+
+	l.evictIdle(now)
+
+	l.mu.Unlock()
+
+	if len(shed) == 0 {
+		return spans
+	}
+
+	kept := make([]model.Span, 0, len(spans))
+	for i, span := range spans {
+		if admitted[i] {
+			kept = append(kept, span)
+		}
+	}
+
+	for service, count := range shed {
+		l.recorder.RateLimitedSpans(service, count)
+	}
+	l.recorder.SpansDropped(metrics.ReasonRateLimit, len(spans)-len(kept))
+
+	l.warn(now, len(spans)-len(kept), shed)
+
+	return kept
+}
+
+func (l *Limiter) Name() string {
+	return "Limiter"
+}
+
+// serviceBucket returns the bucket of a service, creating it on first use, or nil if the service is not limited
+func (l *Limiter) serviceBucket(service string, now time.Time) *tokenBucket {
+	if bucket, ex := l.services[service]; ex {
+		return bucket
+	}
+
+	rate, ex := l.cfg.Services[service]
+	if !ex {
+		rate = l.cfg.ServiceSpansPerSecond
+	}
+
+	if rate <= 0 {
+		return nil
+	}
+
+	bucket := newTokenBucket(rate, l.cfg.Burst, now)
+	l.services[service] = bucket
+
+	return bucket
+}
+
+// evictIdle removes the buckets of services that sent no spans long enough for their bucket to be full again,
+// so that the map does not grow with every service name ever seen. A full bucket is recreated identically.
+func (l *Limiter) evictIdle(now time.Time) {
+	if now.Sub(l.lastEvict) < evictInterval {
+		return
+	}
+
+	for service, bucket := range l.services {
+		bucket.refill(now)
+		if bucket.full() {
+			delete(l.services, service)
+		}
+	}
+
+	l.lastEvict = now
+}
+
+// warn logs shed spans at most once per warnInterval, summing up the spans shed in between
+func (l *Limiter) warn(now time.Time, count int, services map[string]int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.shed += count
+	if now.Sub(l.lastWarn) < warnInterval {
+		return
+	}
+
+	l.logger.Warn("Span budget exceeded, shedding spans",
+		zap.Int("shed", l.shed),
+		zap.Any("services", services),
+		zap.Float64("spans_per_second", l.cfg.SpansPerSecond),
+		zap.Float64("service_spans_per_second", l.cfg.ServiceSpansPerSecond))
+
+	l.shed = 0
+	l.lastWarn = now
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func spans(service string, count int, ec int) []model.Span {
+	spans := make([]model.Span, count)
+	for i := range spans {
+		spans[i] = model.Span{Ec: ec, Data: model.OTelSpanData{ServiceName: service}}
+	}
+
+	return spans
+}
+
+func TestLimiterKeepsErroneousSpans(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newLimiter(zap.NewNop(), nil, config.RateLimitConfig{SpansPerSecond: 10, Burst: time.Second}, func() time.Time { return now })
+
+	batch := append(spans("shop", 8, 0), spans("shop", 5, 1)...)
+	kept := limiter.ProcessSpans(batch)
+
+	assert.Len(t, kept, 10)
+	erroneous := 0
+	for _, span := range kept {
+		erroneous += span.Ec
+	}
+	assert.Equal(t, 5, erroneous)
+
+	assert.Empty(t, limiter.ProcessSpans(spans("shop", 3, 0)))
+
+	now = now.Add(500 * time.Millisecond)
+	assert.Len(t, limiter.ProcessSpans(spans("shop", 10, 0)), 5)
+}
+
+func TestLimiterLimitsPerService(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newLimiter(zap.NewNop(), nil, config.RateLimitConfig{
+		ServiceSpansPerSecond: 5,
+		Services:              map[string]float64{"noisy": 2},
+		Burst:                 time.Second,
+	}, func() time.Time { return now })
+
+	batch := append(spans("noisy", 10, 0), spans("quiet", 4, 0)...)
+	kept := limiter.ProcessSpans(batch)
+
+	counts := make(map[string]int)
+	for _, span := range kept {
+		counts[span.Data.ServiceName]++
+	}
+
+	assert.Equal(t, map[string]int{"noisy": 2, "quiet": 4}, counts)
+}
+
+func TestLimiterAdmitsFractionalBudgets(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newLimiter(zap.NewNop(), nil, config.RateLimitConfig{
+		ServiceSpansPerSecond: 0.5,
+		Burst:                 2 * time.Second,
+	}, func() time.Time { return now })
+
+	assert.Len(t, limiter.ProcessSpans(spans("batch-job", 3, 0)), 1)
+
+	now = now.Add(time.Second)
+	assert.Empty(t, limiter.ProcessSpans(spans("batch-job", 1, 0)))
+
+	now = now.Add(time.Second)
+	assert.Len(t, limiter.ProcessSpans(spans("batch-job", 1, 0)), 1)
+}
+
+func TestLimiterAdmitsBursts(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newLimiter(zap.NewNop(), nil, config.RateLimitConfig{
+		SpansPerSecond: 10,
+		Burst:          3 * time.Second,
+	}, func() time.Time { return now })
+
+	assert.Len(t, limiter.ProcessSpans(spans("shop", 50, 0)), 30)
+
+	// a quiet second saves up one second worth of spans
+	now = now.Add(time.Second)
+	assert.Len(t, limiter.ProcessSpans(spans("shop", 50, 0)), 10)
+
+	// a long pause saves up no more than the burst
+	now = now.Add(time.Minute)
+	assert.Len(t, limiter.ProcessSpans(spans("shop", 50, 0)), 30)
+}
+
+func TestLimiterEvictsIdleServices(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newLimiter(zap.NewNop(), nil, config.RateLimitConfig{
+		ServiceSpansPerSecond: 5,
+		Burst:                 time.Second,
+	}, func() time.Time { return now })
+
+	for _, service := range []string{"a", "b", "c"} {
+		limiter.ProcessSpans(spans(service, 10, 0))
+	}
+	assert.Len(t, limiter.services, 3)
+
+	now = now.Add(evictInterval)
+	assert.Len(t, limiter.ProcessSpans(spans("a", 10, 0)), 5)
+	assert.Len(t, limiter.services, 1)
+}
+
+func TestRateLimitBurstValidation(t *testing.T) {
+	cfg := config.RateLimitConfig{ServiceSpansPerSecond: 0.5, Burst: time.Second}
+	assert.EqualError(t, cfg.Validate(), "rate_limit service_spans_per_second of 0.5 spans per second needs a burst of at least 2s")
+
+	cfg.Burst = 2 * time.Second
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Services = map[string]float64{"b": 0.1, "a": 0.2}
+	assert.EqualError(t, cfg.Validate(), "rate_limit budget of service \"a\" of 0.2 spans per second needs a burst of at least 5s")
+
+	cfg.Burst = 0
+	assert.EqualError(t, cfg.Validate(), "rate_limit burst must be positive")
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// tokenBucket holds up to its capacity as tokens, refilled continuously at its rate
+type tokenBucket struct {
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate float64, burst time.Duration, now time.Time) *tokenBucket {
+	capacity := rate * burst.Seconds()
+
+	return &tokenBucket{
+		rate:     rate,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+func (b *tokenBucket) available() bool {
+	return b.tokens >= 1
+}
+
+func (b *tokenBucket) take() {
+	b.tokens--
+}
+
+// full tells whether the bucket is back at its capacity, in which case it is the same as a new bucket
+func (b *tokenBucket) full() bool {
+	return b.tokens >= b.capacity
+}
@@ -0,0 +1,138 @@
+package sampling
+
+import (
+	"hash/fnv"
+	"math"
+	"time"
+
+	"github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"github.com/ibm-observability/instanaexporter/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// Sampler keeps a consistent percentage of traces, decided by their trace ID, so that all spans of a
+// trace are kept or dropped together. Traces containing an erroneous or slow span are always kept, but
+// only the spans of such a trace that are in the same batch as the erroneous or slow span; spans of the
+// trace exported in other batches follow the probabilistic decision.
+type Sampler struct {
+	logger    *zap.Logger
+	recorder  *metrics.Recorder
+	cfg       config.SamplingConfig
+	threshold uint64
+}
+
+func NewSampler(logger *zap.Logger, recorder *metrics.Recorder, cfg config.SamplingConfig) *Sampler {
+	return &Sampler{
+		logger:    logger,
+		recorder:  recorder,
+		cfg:       cfg,
+		threshold: uint64(cfg.Percentage / 100 * math.MaxUint64),
+	}
+}
+
+type traceDecision struct {
+	keep     bool
+	decision string
+}
+
+func (s *Sampler) ProcessSpans(spans []model.Span) []model.Span {
+	decisions := make(map[string]*traceDecision)
+
+	for i := range spans {
+		key := traceKey(spans[i])
+
+		decision, ex := decisions[key]
+		if !ex {
+			decision = &traceDecision{decision: metrics.DecisionDropped}
+			if s.sampled(key) {
+				decision.keep = true
+				decision.decision = metrics.DecisionSampled
+			}
+
+			decisions[key] = decision
+		}
+
+		// errors take precedence over slow calls, which take precedence over the probabilistic decision
+		if spans[i].Ec > 0 {
+			decision.keep = true
+			decision.decision = metrics.DecisionError
+		} else if s.slow(spans[i]) && decision.decision != metrics.DecisionError {
+			decision.keep = true
+			decision.decision = metrics.DecisionSlow
+		}
+	}
+
+	kept := spans[:0]
+	counts := make(map[string]map[string]int)
+
+	for _, span := range spans {
+		decision := decisions[traceKey(span)]
+
+		if counts[span.Data.ServiceName] == nil {
+			counts[span.Data.ServiceName] = make(map[string]int)
+		}
+		counts[span.Data.ServiceName][decision.decision]++
+
+		if decision.keep {
+			kept = append(kept, span)
+		}
+	}
+
+	s.record(counts)
+
+	if dropped := len(spans) - len(kept); dropped > 0 {
+		s.recorder.SpansDropped(metrics.ReasonSampling, dropped)
+		s.logger.Debug("Sampled out spans", zap.Int("dropped", dropped), zap.Int("kept", len(kept)))
+	}
+
+	return kept
+}
+
+func (s *Sampler) Name() string {
+	return "Sampler"
+}
+
+// sampled makes the probabilistic decision based on the hash of the trace ID, so that it is the same for all
+// spans of a trace, regardless of the batch they are exported in
+func (s *Sampler) sampled(traceKey string) bool {
+	if s.cfg.Percentage >= 100 {
+		return true
+	}
+
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(traceKey))
+
+	return hash.Sum64() < s.threshold
+}
+
+func (s *Sampler) slow(span model.Span) bool {
+	threshold, ex := s.cfg.ServiceSlowThresholds[span.Data.ServiceName]
+	if !ex {
+		threshold = s.cfg.SlowThreshold
+	}
+
+	return threshold > 0 && time.Duration(span.Duration)*time.Millisecond >= threshold
+}
+
+func (s *Sampler) record(counts map[string]map[string]int) {
+	for service, decisions := range counts {
+		total := 0
+		for decision, count := range decisions {
+			s.recorder.SampledSpans(service, decision, count)
+			total += count
+		}
+
+		s.recorder.EffectiveSamplePercentage(service, 100*float64(total-decisions[metrics.DecisionDropped])/float64(total))
+	}
+}
+
+// traceKey identifies the trace of a span by its original OpenTelemetry trace ID, which batch processors
+// do not rewrite. Spans that were not converted from OpenTelemetry spans fall back to the Instana trace ID.
+func traceKey(span model.Span) string {
+	if span.OTelTraceID != "" {
+		return span.OTelTraceID
+	}
+
+	return span.TraceID
+}
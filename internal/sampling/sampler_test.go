@@ -0,0 +1,82 @@
+package sampling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ibm-observability/instanaexporter/config"
+	"github.com/ibm-observability/instanaexporter/internal/converter/model"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func span(traceId string, spanId string, service string, duration uint64, ec int) model.Span {
+	return model.Span{
+		TraceReference: model.TraceReference{TraceID: traceId},
+		SpanID:         spanId,
+		Duration:       duration,
+		Ec:             ec,
+		Data:           model.OTelSpanData{ServiceName: service},
+	}
+}
+
+func TestSamplerKeepsErroneousAndSlowTraces(t *testing.T) {
+	sampler := NewSampler(zap.NewNop(), nil, config.SamplingConfig{
+		Percentage:            0,
+		SlowThreshold:         time.Second,
+		ServiceSlowThresholds: map[string]time.Duration{"fast": 10 * time.Millisecond},
+	})
+
+	spans := sampler.ProcessSpans([]model.Span{
+		span("aaaaaaaaaaaaaaaa", "1", "shop", 5, 0),
+		span("aaaaaaaaaaaaaaaa", "2", "shop", 5, 1),
+		span("bbbbbbbbbbbbbbbb", "3", "shop", 500, 0),
+		span("cccccccccccccccc", "4", "fast", 50, 0),
+		span("dddddddddddddddd", "5", "shop", 1500, 0),
+	})
+
+	ids := make([]string, 0)
+	for _, sp := range spans {
+		ids = append(ids, sp.SpanID)
+	}
+
+	assert.Equal(t, []string{"1", "2", "4", "5"}, ids)
+}
+
+func TestSamplerIsConsistentPerTrace(t *testing.T) {
+	sampler := NewSampler(zap.NewNop(), nil, config.SamplingConfig{Percentage: 50})
+
+	kept := 0
+	for i := 0; i < 1000; i++ {
+		traceId := time.Unix(int64(i), 0).Format(time.RFC3339)
+		spans := sampler.ProcessSpans([]model.Span{
+			span(traceId, "1", "shop", 5, 0),
+			span(traceId, "2", "shop", 5, 0),
+		})
+
+		assert.Contains(t, []int{0, 2}, len(spans))
+		assert.Equal(t, len(spans), len(sampler.ProcessSpans([]model.Span{span(traceId, "3", "shop", 5, 0), span(traceId, "4", "shop", 5, 0)})))
+
+		kept += len(spans) / 2
+	}
+
+	assert.InDelta(t, 500, kept, 100)
+}
+
+func TestSamplerUsesOriginalTraceID(t *testing.T) {
+	sampler := NewSampler(zap.NewNop(), nil, config.SamplingConfig{Percentage: 50})
+
+	for i := 0; i < 100; i++ {
+		otelTraceId := time.Unix(int64(i), 0).Format(time.RFC3339)
+
+		original := span(otelTraceId[4:], "1", "shop", 5, 0)
+		original.LongTraceID = otelTraceId
+		original.OTelTraceID = otelTraceId
+
+		// moved onto an Instana trace ID in another batch
+		moved := span("1234567890abcdef", "2", "shop", 5, 0)
+		moved.OTelTraceID = otelTraceId
+
+		assert.Equal(t, len(sampler.ProcessSpans([]model.Span{original})), len(sampler.ProcessSpans([]model.Span{moved})))
+	}
+}
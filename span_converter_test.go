@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +12,8 @@ import (
 	"github.com/ibm-observability/instanaexporter/internal/converter"
 	"github.com/ibm-observability/instanaexporter/internal/converter/model"
 
+	"go.uber.org/zap"
+
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	conventions "go.opentelemetry.io/collector/semconv/v1.8.0"
@@ -113,6 +116,10 @@ func validateInstanaSpanBasics(sp model.Span, t *testing.T) {
 	if sp.Duration <= 0 {
 		t.Errorf("expected duration to be provided but received %v", sp.Duration)
 	}
+
+	if sp.Kind < model.INSTANA_KIND_ENTRY || sp.Kind > model.INSTANA_KIND_INTERMEDIATE {
+		t.Errorf("expected kind to be entry, exit or intermediate but received %v", sp.Kind)
+	}
 }
 
 func validateBundle(jsonData []byte, t *testing.T, fn func(model.Span, *testing.T)) {
@@ -173,7 +180,7 @@ func TestSpanBasics(t *testing.T) {
 
 	attrs := generateAttrs()
 	conv := converter.SpanConverter{}
-	bundle := conv.ConvertSpans(attrs, spanSlice)
+	bundle := conv.ConvertSpans(attrs, pcommon.NewInstrumentationScope(), spanSlice)
 	data, _ := json.MarshalIndent(bundle, "", "  ")
 
 	validateBundle(data, t, func(sp model.Span, t *testing.T) {
@@ -205,7 +212,7 @@ func TestSpanCorrelation(t *testing.T) {
 
 	attrs := generateAttrs()
 	conv := converter.SpanConverter{}
-	bundle := conv.ConvertSpans(attrs, spanSlice)
+	bundle := conv.ConvertSpans(attrs, pcommon.NewInstrumentationScope(), spanSlice)
 	data, _ := json.MarshalIndent(bundle, "", "  ")
 
 	spanIdList := make(map[string]bool)
@@ -231,7 +238,7 @@ func TestSpanWithError(t *testing.T) {
 
 	attrs := generateAttrs()
 	conv := converter.SpanConverter{}
-	bundle := conv.ConvertSpans(attrs, spanSlice)
+	bundle := conv.ConvertSpans(attrs, pcommon.NewInstrumentationScope(), spanSlice)
 	data, _ := json.MarshalIndent(bundle, "", "  ")
 
 	validateBundle(data, t, func(sp model.Span, t *testing.T) {
@@ -240,6 +247,672 @@ func TestSpanWithError(t *testing.T) {
 	})
 }
 
+func TestSpanOperationNaming(t *testing.T) {
+	spanSlice := ptrace.NewSpanSlice()
+
+	sp1 := spanSlice.AppendEmpty()
+	setupSpan(&sp1, SpanOptions{})
+	sp1.SetKind(ptrace.SpanKindServer)
+	sp1.SetName("HTTP GET")
+	sp1.Attributes().InsertString(conventions.AttributeHTTPMethod, "GET")
+	sp1.Attributes().InsertString(conventions.AttributeHTTPRoute, "/users/42/orders")
+
+	sp2 := spanSlice.AppendEmpty()
+	setupSpan(&sp2, SpanOptions{})
+	sp2.Attributes().InsertString(conventions.AttributeHTTPMethod, "GET")
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.OperationNaming.Rewrites = []config.OperationNameRewrite{
+		{Pattern: "/[0-9]+", Replacement: "/{id}"},
+	}
+
+	conv, err := converter.NewSpanConverter(zap.NewNop(), nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := conv.ConvertSpans(generateAttrs(), pcommon.NewInstrumentationScope(), spanSlice)
+
+	if bundle.Spans[0].Data.Operation != "GET /users/{id}/orders" {
+		t.Errorf("expected operation to be built from the http rule but received '%v'", bundle.Spans[0].Data.Operation)
+	}
+
+	if bundle.Spans[1].Data.Operation != "my_operation" {
+		t.Errorf("expected operation to fall back to the span name but received '%v'", bundle.Spans[1].Data.Operation)
+	}
+}
+
+func TestSpanScope(t *testing.T) {
+	spanSlice := ptrace.NewSpanSlice()
+
+	sp1 := spanSlice.AppendEmpty()
+	setupSpan(&sp1, SpanOptions{})
+
+	scope := pcommon.NewInstrumentationScope()
+	scope.SetName("go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp")
+	scope.SetVersion("0.34.0")
+	scope.Attributes().InsertString("some_scope_key", "ok")
+
+	conv := converter.SpanConverter{}
+	bundle := conv.ConvertSpans(generateAttrs(), scope, spanSlice)
+	data, _ := json.MarshalIndent(bundle, "", "  ")
+
+	validateBundle(data, t, func(sp model.Span, t *testing.T) {
+		if sp.Data.Scope == nil {
+			t.Fatal("expected scope to be set")
+		}
+
+		if sp.Data.Scope.Name != scope.Name() || sp.Data.Scope.Version != scope.Version() {
+			t.Errorf("expected scope %v %v but received %v %v", scope.Name(), scope.Version(), sp.Data.Scope.Name, sp.Data.Scope.Version)
+		}
+
+		if sp.Data.Scope.Attributes["some_scope_key"] != "ok" {
+			t.Error("expected scope attributes to be set")
+		}
+	})
+}
+
+func TestSpanServiceNameResolution(t *testing.T) {
+	spanSlice := ptrace.NewSpanSlice()
+
+	sp1 := spanSlice.AppendEmpty()
+	setupSpan(&sp1, SpanOptions{})
+
+	sp2 := spanSlice.AppendEmpty()
+	setupSpan(&sp2, SpanOptions{})
+	sp2.Attributes().InsertString("instana.service", "payments")
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.ServiceName.UseNamespace = true
+	cfg.ServiceName.OverrideAttribute = "instana.service"
+
+	conv, err := converter.NewSpanConverter(zap.NewNop(), nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attrs := generateAttrs()
+	attrs.UpsertString(conventions.AttributeServiceName, "unknown_service:java")
+	attrs.UpsertString(conventions.AttributeK8SDeploymentName, "checkout")
+	attrs.UpsertString(conventions.AttributeServiceNamespace, "shop")
+
+	bundle := conv.ConvertSpans(attrs, pcommon.NewInstrumentationScope(), spanSlice)
+
+	if bundle.Spans[0].Data.ServiceName != "shop/checkout" {
+		t.Errorf("expected service name to fall back to the deployment name but received '%v'", bundle.Spans[0].Data.ServiceName)
+	}
+
+	if bundle.Spans[1].Data.ServiceName != "payments" {
+		t.Errorf("expected service name to be overridden by the span but received '%v'", bundle.Spans[1].Data.ServiceName)
+	}
+
+	attrs = pcommon.NewMap()
+	attrs.UpsertString(conventions.AttributeServiceNamespace, "shop")
+	bundle = conv.ConvertSpans(attrs, pcommon.NewInstrumentationScope(), spanSlice)
+
+	if bundle.Spans[0].Data.ServiceName != cfg.ServiceName.Default {
+		t.Errorf("expected default service name without namespace but received '%v'", bundle.Spans[0].Data.ServiceName)
+	}
+
+	scope := pcommon.NewInstrumentationScope()
+	scope.SetName("io.opentelemetry.jdbc")
+	bundle = conv.ConvertSpans(attrs, scope, spanSlice)
+
+	if bundle.Spans[0].Data.ServiceName != "io.opentelemetry.jdbc" {
+		t.Errorf("expected scope name without namespace but received '%v'", bundle.Spans[0].Data.ServiceName)
+	}
+}
+
+func TestSpanEUMCorrelation(t *testing.T) {
+	levelHeader := pcommon.NewValueSlice()
+	levelHeader.SliceVal().AppendEmpty().SetStringVal("1,correlationType=web;correlationId=abc123")
+
+	tests := []struct {
+		name          string
+		kind          ptrace.SpanKind
+		attributes    map[string]pcommon.Value
+		traceState    ptrace.TraceState
+		useTraceState bool
+		crtp          string
+		crid          string
+		synthetic     bool
+	}{
+		{
+			name: "attributes",
+			kind: ptrace.SpanKindServer,
+			attributes: map[string]pcommon.Value{
+				"instana.correlation.type": pcommon.NewValueString("mobile"),
+				"instana.correlation.id":   pcommon.NewValueString("m-1"),
+				"instana.synthetic":        pcommon.NewValueString("1"),
+			},
+			crtp:      "mobile",
+			crid:      "m-1",
+			synthetic: true,
+		},
+		{
+			name: "baggage",
+			kind: ptrace.SpanKindServer,
+			attributes: map[string]pcommon.Value{
+				"http.request.header.baggage": pcommon.NewValueString("instana-correlation-type=web,instana-correlation-id=b%2D1;p=1,instana-synthetic=true,other=1"),
+			},
+			crtp:      "web",
+			crid:      "b-1",
+			synthetic: true,
+		},
+		{
+			name: "baggage ignores attribute names",
+			kind: ptrace.SpanKindServer,
+			attributes: map[string]pcommon.Value{
+				"http.request.header.baggage": pcommon.NewValueString("instana.correlation.id=x,instana.synthetic=true"),
+			},
+		},
+		{
+			name: "level header",
+			kind: ptrace.SpanKindServer,
+			attributes: map[string]pcommon.Value{
+				"http.request.header.x_instana_l": levelHeader,
+			},
+			crtp: "web",
+			crid: "abc123",
+		},
+		{
+			name: "synthetic header",
+			kind: ptrace.SpanKindConsumer,
+			attributes: map[string]pcommon.Value{
+				"http.request.header.x_instana_synthetic": pcommon.NewValueString("1"),
+			},
+			synthetic: true,
+		},
+		{
+			name: "tracestate",
+			kind: ptrace.SpanKindServer,
+			attributes: map[string]pcommon.Value{
+				"http.request.header.baggage": pcommon.NewValueString("instana-correlation-type=web"),
+			},
+			traceState:    "rojo=00f067aa0ba902b7,in=fa2375d711a4ca0f;02468acefdb97531",
+			useTraceState: true,
+			crtp:          "web",
+			crid:          "fa2375d711a4ca0f",
+		},
+		{
+			name:          "tracestate without correlation type",
+			kind:          ptrace.SpanKindServer,
+			traceState:    "in=fa2375d711a4ca0f;02468acefdb97531",
+			useTraceState: true,
+		},
+		{
+			name: "tracestate disabled by default",
+			kind: ptrace.SpanKindServer,
+			attributes: map[string]pcommon.Value{
+				"instana.correlation.type": pcommon.NewValueString("web"),
+			},
+			traceState: "in=fa2375d711a4ca0f;02468acefdb97531",
+			crtp:       "web",
+		},
+		{
+			name: "level header before tracestate",
+			kind: ptrace.SpanKindServer,
+			attributes: map[string]pcommon.Value{
+				"http.request.header.x_instana_l": levelHeader,
+			},
+			traceState:    "in=fa2375d711a4ca0f;02468acefdb97531",
+			useTraceState: true,
+			crtp:          "web",
+			crid:          "abc123",
+		},
+		{
+			name: "exit span",
+			kind: ptrace.SpanKindClient,
+			attributes: map[string]pcommon.Value{
+				"instana.correlation.id": pcommon.NewValueString("ignored-on-exit-spans"),
+			},
+			traceState: "in=fa2375d711a4ca0f;02468acefdb97531",
+		},
+	}
+
+	for _, test := range tests {
+		cfg := createDefaultConfig().(*config.Config)
+		cfg.Correlation.UseTraceState = test.useTraceState
+
+		conv, err := converter.NewSpanConverter(zap.NewNop(), nil, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		spanSlice := ptrace.NewSpanSlice()
+		sp := spanSlice.AppendEmpty()
+		setupSpan(&sp, SpanOptions{})
+		sp.SetKind(test.kind)
+		sp.SetTraceState(test.traceState)
+
+		for key, value := range test.attributes {
+			sp.Attributes().Insert(key, value)
+		}
+
+		span := conv.ConvertSpans(generateAttrs(), pcommon.NewInstrumentationScope(), spanSlice).Spans[0]
+
+		if span.CorrelationType != test.crtp || span.CorrelationID != test.crid || span.Synthetic != test.synthetic {
+			t.Errorf("%s: expected correlation %q/%q synthetic %v but received %q/%q synthetic %v",
+				test.name, test.crtp, test.crid, test.synthetic, span.CorrelationType, span.CorrelationID, span.Synthetic)
+		}
+	}
+}
+
+func TestSpanInstanaTraceContinuity(t *testing.T) {
+	spanSlice := ptrace.NewSpanSlice()
+
+	sp1 := spanSlice.AppendEmpty()
+	setupSpan(&sp1, SpanOptions{
+		ParentId: [8]byte{1, 2, 3, 4, 5, 6, 7, 8},
+	})
+	sp1.SetKind(ptrace.SpanKindServer)
+	sp1.SetTraceState("in=1234567890abcdef;0102030405060708")
+
+	sp2 := spanSlice.AppendEmpty()
+	setupSpan(&sp2, SpanOptions{
+		TraceId:  sp1.TraceID().Bytes(),
+		ParentId: sp1.SpanID().Bytes(),
+	})
+
+	conv := converter.SpanConverter{}
+	bundle := conv.ConvertSpans(generateAttrs(), pcommon.NewInstrumentationScope(), spanSlice)
+
+	entry := bundle.Spans[0]
+	if entry.Ancestor == nil || entry.Ancestor.TraceID != "1234567890abcdef" || entry.Ancestor.ParentID != "0102030405060708" {
+		t.Fatalf("expected Instana ancestor to be parsed from the tracestate but received %v", entry.Ancestor)
+	}
+
+	if !entry.ForeignTrace || !entry.Data.HasTraceParent {
+		t.Error("expected entry span to continue a foreign trace before processing")
+	}
+
+	originalTraceId := entry.TraceID
+	longTraceId := entry.LongTraceID
+
+	cfg := createDefaultConfig().(*config.Config)
+	if cfg.TraceContinuity.ReuseInstanaTraceID {
+		t.Error("expected reusing the Instana trace id to be disabled by default")
+	}
+
+	processors, err := converter.NewBatchProcessors(zap.NewNop(), nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := append([]model.Span(nil), bundle.Spans...)
+	for _, processor := range processors {
+		spans = processor.ProcessSpans(spans)
+	}
+
+	for _, sp := range spans {
+		if sp.TraceID != originalTraceId {
+			t.Errorf("expected span %v to keep its trace id by default but received %v", sp.SpanID, sp.TraceID)
+		}
+	}
+
+	cfg.TraceContinuity.ReuseInstanaTraceID = true
+	processors, err = converter.NewBatchProcessors(zap.NewNop(), nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans = bundle.Spans
+	for _, processor := range processors {
+		spans = processor.ProcessSpans(spans)
+	}
+
+	for _, sp := range spans {
+		if sp.TraceID != "1234567890abcdef" {
+			t.Errorf("expected span %v to be moved onto the Instana trace id but received %v", sp.SpanID, sp.TraceID)
+		}
+
+		if sp.LongTraceID != longTraceId {
+			t.Errorf("expected span %v to keep the long trace id %v but received %v", sp.SpanID, longTraceId, sp.LongTraceID)
+		}
+
+		if sp.ForeignTrace {
+			t.Errorf("expected span %v not to be marked as foreign trace", sp.SpanID)
+		}
+	}
+}
+
+func TestSpanKinds(t *testing.T) {
+	spanSlice := ptrace.NewSpanSlice()
+
+	for _, kind := range []ptrace.SpanKind{ptrace.SpanKindServer, ptrace.SpanKindClient, ptrace.SpanKindUnspecified, ptrace.SpanKindInternal} {
+		sp := spanSlice.AppendEmpty()
+		setupSpan(&sp, SpanOptions{})
+		sp.SetKind(kind)
+	}
+
+	scope := pcommon.NewInstrumentationScope()
+	scope.SetName("my-job-library")
+
+	cfg := createDefaultConfig().(*config.Config)
+	cfg.SpanKind.Overrides = []config.SpanKindOverride{
+		{Scope: scope.Name(), SpanKinds: []string{"internal"}, Kind: config.InstanaKindEntry},
+	}
+
+	conv, err := converter.NewSpanConverter(zap.NewNop(), nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := conv.ConvertSpans(generateAttrs(), scope, spanSlice)
+
+	expected := []int{model.INSTANA_KIND_ENTRY, model.INSTANA_KIND_EXIT, model.INSTANA_KIND_INTERMEDIATE, model.INSTANA_KIND_ENTRY}
+	for i, sp := range bundle.Spans {
+		if sp.Kind != expected[i] {
+			t.Errorf("expected span %d (%v) to have kind %v but received %v", i, sp.Data.Kind, expected[i], sp.Kind)
+		}
+	}
+}
+
+func TestErrorClassificationValidation(t *testing.T) {
+	cfg := config.DefaultErrorClassificationConfig()
+	cfg.HTTPClientStatusCodes = []string{"4xx"}
+	cfg.GRPCServerStatusCodes = []string{"x"}
+	cfg.GRPCClientStatusCodes = []string{"y"}
+
+	for i := 0; i < 10; i++ {
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "http_client_status_codes") {
+			t.Fatalf("expected the first invalid option to be reported but received %v", err)
+		}
+	}
+}
+
+func TestSpanErrorClassification(t *testing.T) {
+	spanSlice := ptrace.NewSpanSlice()
+
+	sp1 := spanSlice.AppendEmpty()
+	setupSpan(&sp1, SpanOptions{})
+	sp1.SetKind(ptrace.SpanKindServer)
+	sp1.Attributes().InsertInt(conventions.AttributeHTTPStatusCode, 503)
+
+	sp2 := spanSlice.AppendEmpty()
+	setupSpan(&sp2, SpanOptions{
+		Error: "some error",
+	})
+	for _, message := range []string{"first", "second"} {
+		event := sp2.Events().AppendEmpty()
+		event.SetName("exception")
+		event.Attributes().InsertString(conventions.AttributeExceptionType, "IOException")
+		event.Attributes().InsertString(conventions.AttributeExceptionMessage, message)
+	}
+
+	sp3 := spanSlice.AppendEmpty()
+	setupSpan(&sp3, SpanOptions{})
+	sp3.SetKind(ptrace.SpanKindServer)
+	sp3.Attributes().InsertInt(conventions.AttributeHTTPStatusCode, 404)
+
+	conv, err := converter.NewSpanConverter(zap.NewNop(), nil, createDefaultConfig().(*config.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := conv.ConvertSpans(generateAttrs(), pcommon.NewInstrumentationScope(), spanSlice)
+
+	validateSpanError(bundle.Spans[0], true, t)
+	if bundle.Spans[0].Data.Tags[model.INSTANA_DATA_ERROR_DETAIL] != "HTTP status code 503" {
+		t.Errorf("expected error detail from the http status code but received '%v'", bundle.Spans[0].Data.Tags[model.INSTANA_DATA_ERROR_DETAIL])
+	}
+
+	validateSpanError(bundle.Spans[1], true, t)
+	if bundle.Spans[1].Ec != 2 {
+		t.Errorf("expected error count of 2 exceptions but received %v", bundle.Spans[1].Ec)
+	}
+
+	if bundle.Spans[1].Data.Tags[model.INSTANA_DATA_ERROR_DETAIL] != "IOException: second" {
+		t.Errorf("expected error detail from the last exception but received '%v'", bundle.Spans[1].Data.Tags[model.INSTANA_DATA_ERROR_DETAIL])
+	}
+
+	validateSpanError(bundle.Spans[2], false, t)
+}
+
+func TestSpanUnsampledPolicy(t *testing.T) {
+	spanSlice := ptrace.NewSpanSlice()
+
+	sp1 := spanSlice.AppendEmpty()
+	setupSpan(&sp1, SpanOptions{})
+
+	sp2 := spanSlice.AppendEmpty()
+	setupSpan(&sp2, SpanOptions{})
+	sp2.SetTraceState("ot=p:63;r:10")
+
+	sp3 := spanSlice.AppendEmpty()
+	setupSpan(&sp3, SpanOptions{})
+	sp3.Attributes().InsertInt("sampling.priority", 0)
+
+	cfg := createDefaultConfig().(*config.Config)
+
+	cfg.Unsampled.Policy = config.UnsampledPolicyDrop
+	conv, err := converter.NewSpanConverter(zap.NewNop(), nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := conv.ConvertSpans(generateAttrs(), pcommon.NewInstrumentationScope(), spanSlice)
+	if len(bundle.Spans) != 1 || bundle.Spans[0].SpanID != sp1.SpanID().HexString() {
+		t.Errorf("expected only the sampled span to be converted but received %d spans", len(bundle.Spans))
+	}
+
+	cfg.Unsampled.Policy = config.UnsampledPolicySynthetic
+	conv, err = converter.NewSpanConverter(zap.NewNop(), nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle = conv.ConvertSpans(generateAttrs(), pcommon.NewInstrumentationScope(), spanSlice)
+	for i, expected := range []bool{false, true, true} {
+		if bundle.Spans[i].Synthetic != expected {
+			t.Errorf("expected span %d synthetic to be %v", i, expected)
+		}
+	}
+}
+
+func TestSpanExclusion(t *testing.T) {
+	spanSlice := ptrace.NewSpanSlice()
+
+	sp1 := spanSlice.AppendEmpty()
+	setupSpan(&sp1, SpanOptions{})
+	sp1.SetKind(ptrace.SpanKindServer)
+	sp1.Attributes().InsertString(conventions.AttributeHTTPTarget, "/healthz")
+
+	sp2 := spanSlice.AppendEmpty()
+	setupSpan(&sp2, SpanOptions{
+		TraceId:  sp1.TraceID().Bytes(),
+		ParentId: sp1.SpanID().Bytes(),
+	})
+
+	sp3 := spanSlice.AppendEmpty()
+	setupSpan(&sp3, SpanOptions{})
+	sp3.SetKind(ptrace.SpanKindServer)
+	sp3.Attributes().InsertString(conventions.AttributeHTTPTarget, "/orders")
+
+	conv := converter.SpanConverter{}
+	bundle := conv.ConvertSpans(generateAttrs(), pcommon.NewInstrumentationScope(), spanSlice)
+
+	processor, err := converter.NewExclusionProcessor(zap.NewNop(), nil, []config.ExclusionRule{
+		{
+			Name:            "health",
+			SpanKinds:       []string{"server"},
+			Attributes:      []config.AttributeMatch{{Key: conventions.AttributeHTTPTarget, Pattern: "^/(healthz|metrics)$"}},
+			DropDescendants: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := processor.ProcessSpans(bundle.Spans)
+
+	if len(spans) != 1 || spans[0].SpanID != sp3.SpanID().HexString() {
+		t.Errorf("expected the health check and its descendant to be dropped but received %d spans", len(spans))
+	}
+}
+
+func TestSpanBatching(t *testing.T) {
+	spanSlice := ptrace.NewSpanSlice()
+
+	parent := spanSlice.AppendEmpty()
+	setupSpan(&parent, SpanOptions{})
+	parent.SetKind(ptrace.SpanKindServer)
+
+	start := time.Duration(time.Now().UnixMilli())
+	for i := 0; i < 5; i++ {
+		sp := spanSlice.AppendEmpty()
+		setupSpan(&sp, SpanOptions{
+			TraceId:        parent.TraceID().Bytes(),
+			ParentId:       parent.SpanID().Bytes(),
+			StartTimestamp: start + time.Duration(i*2),
+			EndTimestamp:   start + time.Duration(i*2+1),
+		})
+		sp.SetName("SELECT orders")
+
+		if i == 3 {
+			sp.Status().SetCode(ptrace.StatusCodeError)
+		}
+	}
+
+	conv := converter.SpanConverter{}
+	bundle := conv.ConvertSpans(generateAttrs(), pcommon.NewInstrumentationScope(), spanSlice)
+
+	firstTimestamp := bundle.Spans[1].Timestamp
+	spans := converter.NewBatchingProcessor(zap.NewNop(), 10*time.Millisecond).ProcessSpans(bundle.Spans)
+
+	if len(spans) != 2 {
+		t.Fatalf("expected the queries to be aggregated into one batch span but received %d spans", len(spans))
+	}
+
+	batch := spans[1]
+	if batch.Batch == nil || batch.Batch.Size != 5 {
+		t.Fatalf("expected a batch of 5 spans but received %v", batch.Batch)
+	}
+
+	if batch.Duration != 5 {
+		t.Errorf("expected the summed duration 5 but received %v", batch.Duration)
+	}
+
+	if batch.Timestamp != firstTimestamp {
+		t.Errorf("expected the batch span to start with the first query at %v but received %v", firstTimestamp, batch.Timestamp)
+	}
+
+	if data, _ := json.Marshal(batch.Batch); string(data) != `{"s":5}` {
+		t.Errorf("expected batch info %s but received %s", `{"s":5}`, data)
+	}
+
+	if batch.Ec != 1 {
+		t.Errorf("expected merged error count 1 but received %v", batch.Ec)
+	}
+}
+
+func TestSpanRootEntry(t *testing.T) {
+	spanSlice := ptrace.NewSpanSlice()
+
+	root := spanSlice.AppendEmpty()
+	setupSpan(&root, SpanOptions{
+		Error: "job failed",
+	})
+	root.SetKind(ptrace.SpanKindInternal)
+
+	child := spanSlice.AppendEmpty()
+	setupSpan(&child, SpanOptions{
+		TraceId:  root.TraceID().Bytes(),
+		ParentId: root.SpanID().Bytes(),
+	})
+
+	conv := converter.SpanConverter{}
+
+	bundle := conv.ConvertSpans(generateAttrs(), pcommon.NewInstrumentationScope(), spanSlice)
+	spans := converter.NewRootEntryProcessor(zap.NewNop(), config.RootEntryModeSynthesize).ProcessSpans(bundle.Spans)
+
+	if len(spans) != 3 {
+		t.Fatalf("expected an entry span to be synthesized but received %d spans", len(spans))
+	}
+
+	entry := spans[2]
+	if entry.Kind != model.INSTANA_KIND_ENTRY || entry.ParentID != "" || spans[0].ParentID != entry.SpanID {
+		t.Error("expected the synthesized entry span to become the parent of the root span")
+	}
+
+	if entry.Data.Tags[model.INSTANA_DATA_SYNTHETIC_ENTRY] == "" {
+		t.Error("expected the synthesized entry span to be marked")
+	}
+
+	// the error stays on the root span, copying it would count it twice
+	if entry.Ec != 0 || spans[0].Ec != 1 {
+		t.Errorf("expected error count 0 on the synthesized entry and 1 on the root span but received %v and %v", entry.Ec, spans[0].Ec)
+	}
+
+	bundle = conv.ConvertSpans(generateAttrs(), pcommon.NewInstrumentationScope(), spanSlice)
+	spans = converter.NewRootEntryProcessor(zap.NewNop(), config.RootEntryModeRekind).ProcessSpans(bundle.Spans)
+
+	if len(spans) != 2 || spans[0].Kind != model.INSTANA_KIND_ENTRY || spans[1].Kind != model.INSTANA_KIND_EXIT {
+		t.Error("expected only the root span to be turned into an entry span")
+	}
+}
+
+func TestSpanInternalSpanFolding(t *testing.T) {
+	spanSlice := ptrace.NewSpanSlice()
+
+	entry := spanSlice.AppendEmpty()
+	setupSpan(&entry, SpanOptions{})
+	entry.SetKind(ptrace.SpanKindServer)
+
+	parent := entry
+	for i := 0; i < 2; i++ {
+		internal := spanSlice.AppendEmpty()
+		setupSpan(&internal, SpanOptions{
+			TraceId:  entry.TraceID().Bytes(),
+			ParentId: parent.SpanID().Bytes(),
+		})
+		internal.SetKind(ptrace.SpanKindInternal)
+		if i == 1 {
+			internal.Status().SetCode(ptrace.StatusCodeError)
+		}
+		parent = internal
+	}
+
+	exit := spanSlice.AppendEmpty()
+	setupSpan(&exit, SpanOptions{
+		TraceId:  entry.TraceID().Bytes(),
+		ParentId: parent.SpanID().Bytes(),
+	})
+
+	conv := converter.SpanConverter{}
+	bundle := conv.ConvertSpans(generateAttrs(), pcommon.NewInstrumentationScope(), spanSlice)
+
+	spans := converter.NewInternalSpanFoldingProcessor(zap.NewNop(), config.InternalSpansModeAnnotate).ProcessSpans(bundle.Spans)
+
+	if len(spans) != 2 {
+		t.Fatalf("expected the internal spans to be folded but received %d spans", len(spans))
+	}
+
+	if spans[1].ParentID != spans[0].SpanID {
+		t.Errorf("expected the exit span to be re-parented to the entry span but its parent is %v", spans[1].ParentID)
+	}
+
+	if len(spans[0].Data.Folded) != 2 || spans[0].Data.Folded[0].Operation != "my_operation" || spans[0].Data.Folded[1].Ec != 1 {
+		t.Errorf("expected the entry span to be annotated with the folded spans but received %v", spans[0].Data.Folded)
+	}
+
+	if spans[0].Ec != 1 {
+		t.Errorf("expected the entry span to take over the folded error but received error count %v", spans[0].Ec)
+	}
+
+	bundle = conv.ConvertSpans(generateAttrs(), pcommon.NewInstrumentationScope(), spanSlice)
+	spans = converter.NewInternalSpanFoldingProcessor(zap.NewNop(), config.InternalSpansModeDrop).ProcessSpans(bundle.Spans)
+
+	if len(spans) != 2 || len(spans[0].Data.Folded) != 0 {
+		t.Fatalf("expected the internal spans to be dropped but received %d spans", len(spans))
+	}
+
+	if spans[0].Ec != 1 {
+		t.Errorf("expected the entry span to take over the dropped error but received error count %v", spans[0].Ec)
+	}
+}
+
 func generateTraceId() (data [16]byte) {
 	rand.Read(data[:])
 